@@ -28,6 +28,8 @@ pub trait Processor<QueueItem: serde::de::DeserializeOwned + serde::Serialize +
 
 pub trait Identifiable {
     fn id(&self) -> String;
+    // epoch seconds the item entered the queue
+    fn join_time(&self) -> i64;
 }
 
 const CONCURRENT_REQUESTS: usize = 4;
@@ -52,6 +54,11 @@ pub async fn init() {
     ALARM_NAMES.set(static_alarm_names).expect("could not save alarm names");
 }
 
+// structured log line, turned into a cloudwatch metric by a log metric filter
+pub fn metric(name: &str, value: f64) {
+    println!("{{\"metric\":\"{}\",\"value\":{}}}", name, value);
+}
+
 pub fn match_id<QueueItem: Identifiable>(players: &Vec<QueueItem>) -> String {
     let mut ids: Vec<String> = players.iter().map(|player| player.id()).collect();
     ids.sort();
@@ -134,6 +141,7 @@ async fn run<QueueItem: serde::de::DeserializeOwned + serde::Serialize + Identif
         }
         false => {
             println!("lock not held, abandoning stream");
+            metric("LockContention", 1.0);
             // todo: consider custom error so that wrapper can distinguish this from an actual success
             return Ok(());
         }
@@ -148,6 +156,7 @@ async fn run<QueueItem: serde::de::DeserializeOwned + serde::Serialize + Identif
         }
     };
 
+    metric("QueueDepth", items.len() as f64);
     if items.len() == 0 {
         println!("queue is empty");
         return Ok(());
@@ -167,16 +176,21 @@ async fn run<QueueItem: serde::de::DeserializeOwned + serde::Serialize + Identif
 
     // publish matches
     let publish_results = stream::iter(matches).map(|players| {
+        let waits: Vec<i64> = players.iter().map(|player| execution_id.timestamp_seconds() - player.join_time()).collect();
         async move {
-            client.publish_match(execution_id, players).await
+            (client.publish_match(execution_id, players).await, waits)
         }
     }).buffer_unordered(CONCURRENT_REQUESTS);
 
-    // wait on results
-    let any_match_success = publish_results.any(|result| async {
+    // wait on every result, stopping early would leave the rest unpublished
+    let published: Vec<bool> = publish_results.map(|(result, waits)| {
         match result {
             Ok(id) => {
                 println!("published match: {}", id);
+                metric("MatchesMade", 1.0);
+                for wait in waits {
+                    metric("QueueWaitTime", wait as f64);
+                }
                 true
             },
             Err(e) => {
@@ -184,7 +198,8 @@ async fn run<QueueItem: serde::de::DeserializeOwned + serde::Serialize + Identif
                 false
             }
         }
-    }).await;
+    }).collect().await;
+    let any_match_success = published.iter().any(|success| *success);
 
     // todo: inc wait count on unmatched
 
@@ -0,0 +1,166 @@
+use aws_lambda_events::event::cloudwatch_events::CloudWatchEvent;
+use aws_sdk_cloudwatch as cloudwatch;
+use futures::future::join_all;
+use lambda_runtime::{service_fn, Error, LambdaEvent};
+use serde::{Serialize, Deserialize};
+use std::time::{Duration, Instant};
+
+struct Client {
+    client:        appsync::Client,
+    region:        String,
+    namespace:     String,
+    users:         Vec<String>,
+    deadline:      Duration,
+    metric_client: cloudwatch::Client,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize, std::default::Default)]
+struct JoinResponse {
+    #[serde(rename = "joinHealthcheckQueue")]
+    notification: Option<QueueNotification>,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize, std::default::Default)]
+struct QueueNotification {
+    #[serde(rename = "__typename")]
+    kind: String,
+    #[serde(rename = "playerIds", default)]
+    player_ids: Vec<String>,
+}
+
+#[derive(Debug, Clone, Serialize)]
+struct JoinVariables {
+    #[serde(rename = "userId")]
+    user_id: String,
+}
+
+const JOIN_QUERY: &str = "
+subscription($userId: String!){
+    joinHealthcheckQueue(userId: $userId) {
+        __typename
+        ... on Match {
+            playerIds
+        }
+    }
+}
+";
+
+impl Client {
+    async fn new() -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let config = aws_config::load_from_env().await;
+        let region = config.region().ok_or("No region in config")?.clone();
+        let api = std::env::var("API_URL")?;
+        let namespace = std::env::var("METRIC_NAMESPACE")?;
+        let users = std::env::var("USER_IDS")?.split(",").map(str::to_string).collect();
+        let deadline = Duration::from_secs(std::env::var("DEADLINE_SECONDS")?.parse()?);
+        let client = appsync::Client::new(config.clone(), region.clone(), api).await?;
+        let metric_client = cloudwatch::Client::new(&config);
+        Ok(Self { client, region: region.to_string(), namespace, users, deadline, metric_client })
+    }
+
+    async fn run(&self, event: LambdaEvent<CloudWatchEvent>) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        println!("event id: {:?}", event.payload.id);
+        let started = Instant::now();
+
+        // a failed check is reported through the metric, not the invocation, so lambda error alarms stay quiet
+        let success = match tokio::time::timeout(self.deadline, self.check()).await {
+            Ok(Ok(_)) => {
+                println!("canary users matched in {}ms", started.elapsed().as_millis());
+                true
+            },
+            Ok(Err(e)) => {
+                println!("canary failed: {:?}", e);
+                false
+            },
+            Err(_) => {
+                println!("canary users were not matched within {}s", self.deadline.as_secs());
+                false
+            }
+        };
+
+        let mut metrics = vec![
+            self.datum("CanarySuccess", if success { 1.0 } else { 0.0 }, cloudwatch::model::StandardUnit::Count),
+        ];
+        if success {
+            metrics.push(self.datum("CanaryMatchLatency", started.elapsed().as_millis() as f64, cloudwatch::model::StandardUnit::Milliseconds));
+        }
+
+        self.metric_client.put_metric_data()
+            .namespace(&self.namespace)
+            .set_metric_data(Some(metrics))
+            .send().await?;
+
+        Ok(())
+    }
+
+    // queue every synthetic user at once and wait for all of them to be matched together
+    async fn check(&self) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let results = join_all(self.users.iter().map(|user| self.wait_for_match(user))).await;
+        for result in results {
+            result?;
+        }
+        Ok(())
+    }
+
+    async fn wait_for_match(&self, user: &String) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        let req = appsync::GraphqlRequest{
+            query: JOIN_QUERY.to_string(),
+            variables: JoinVariables{user_id: user.clone()},
+        };
+
+        let users = &self.users;
+        let deadline_ms = self.deadline.as_millis() as u64;
+        self.client.subscribe(req, |response: appsync::GraphqlResponse<JoinResponse>| async move {
+            process_subscription(response, users)
+        }, 2000, deadline_ms).await
+    }
+
+    fn datum(&self, name: &str, value: f64, unit: cloudwatch::model::StandardUnit) -> cloudwatch::model::MetricDatum {
+        cloudwatch::model::MetricDatum::builder()
+            .metric_name(name)
+            .dimensions(cloudwatch::model::Dimension::builder().name("Region").value(&self.region).build())
+            .value(value)
+            .unit(unit)
+            .build()
+    }
+}
+
+fn process_subscription(response: appsync::GraphqlResponse<JoinResponse>, users: &Vec<String>) -> Result<Option<()>, String> {
+    println!("{:?}", response);
+    if response.errors.iter().len() > 0 {
+        return Err("graphql call failed".to_string());
+    }
+
+    match response.data.notification {
+        Some(notification) if notification.kind == "Match" => {
+            // the processor only pairs users from the same region, anything else means the queue is misbehaving
+            match users.iter().all(|user| notification.player_ids.contains(user)) {
+                // exit as soon as we get our match
+                true => Ok(None),
+                false => Err(format!("matched with unexpected players: {:?}", notification.player_ids))
+            }
+        },
+        // heartbeats, keep waiting
+        _ => Ok(Some(()))
+    }
+}
+
+#[tokio::main]
+async fn main() -> Result<(), Error> {
+    tracing_subscriber::fmt()
+        .with_max_level(tracing::Level::WARN)
+        .with_target(false)
+        .without_time()
+        .init();
+
+    let client = Client::new().await?;
+    let client_ref = &client;
+
+    // Define a closure here that makes use of the shared client.
+    let handler_func_closure = move |event: LambdaEvent<CloudWatchEvent>| async move {
+        client_ref.run(event).await
+    };
+
+    lambda_runtime::run(service_fn(handler_func_closure)).await?;
+    Ok(())
+}
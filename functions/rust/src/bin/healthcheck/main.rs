@@ -1,14 +1,27 @@
 use aws_lambda_events::event::cloudwatch_events::CloudWatchEvent;
+use aws_sdk_cloudwatch as cloudwatch;
 use aws_sdk_dynamodb as dynamodb;
+use futures::future::join_all;
 use lambda_runtime::{service_fn, Error, LambdaEvent};
 use serde::{Serialize, Deserialize};
+use std::time::{Duration, Instant};
 use svix_ksuid::*;
 
 struct Client {
     client:        appsync::Client,
     region:        String,
     table:         String,
+    namespace:     String,
     dynamo_client: dynamodb::Client,
+    probes:        Vec<Probe>,
+}
+
+// another region's api, checked from here
+struct Probe {
+    region:        String,
+    client:        appsync::Client,
+    // metrics go to the probed region so its alarm can see every prober
+    metric_client: cloudwatch::Client,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize, std::default::Default)]
@@ -21,6 +34,14 @@ struct Healthcheck {
     id: String,
 }
 
+#[derive(Debug, Clone, Serialize, Deserialize, std::default::Default)]
+struct RegionResponse {
+    region: String,
+}
+
+#[derive(Debug, Clone, Serialize)]
+struct NoVariables {}
+
 const HEALTCHECK_QUERY: &str = "
 subscription($id: ID!){
     healthcheck(id: $id) {
@@ -29,19 +50,65 @@ subscription($id: ID!){
 }
 ";
 
+const PROBE_QUERY: &str = "
+query {
+    region
+}
+";
+
+const PROBE_TIMEOUT: Duration = Duration::from_secs(5);
+
+// probe results outlive a few missed runs, then clean themselves up
+const PROBE_TTL_SECONDS: i64 = 600;
+
 impl Client {
     async fn new() -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
         let config = aws_config::load_from_env().await;
         let region = config.region().ok_or("No region in config")?.clone();
         let api = std::env::var("API_URL")?;
         let table = std::env::var("TABLE")?;
+        let namespace = std::env::var("METRIC_NAMESPACE")?;
         let client = appsync::Client::new(config.clone(), region.clone(), api).await?;
         let dynamo_client = dynamodb::Client::new(&config);
-        Ok(Self { client, region: region.to_string(), table, dynamo_client })
+
+        // <region>=<url> pairs, empty when there's nothing to probe
+        let mut probes = Vec::new();
+        for probe in std::env::var("PROBE_URLS")?.split(",").filter(|probe| !probe.is_empty()) {
+            let (probe_region, probe_url) = probe.split_once("=").ok_or("invalid probe url")?;
+            let regional_region = aws_types::region::Region::new(probe_region.to_string());
+            let regional_config = cloudwatch::config::Builder::from(&config)
+                .region(regional_region.clone())
+                .build();
+            probes.push(Probe {
+                region: probe_region.to_string(),
+                client: appsync::Client::new(config.clone(), regional_region, probe_url.to_string()).await?,
+                metric_client: cloudwatch::Client::from_conf(regional_config),
+            });
+        }
+
+        Ok(Self { client, region: region.to_string(), table, namespace, dynamo_client, probes })
     }
 
     async fn run(&self, event: LambdaEvent<CloudWatchEvent>) -> Result<(),  Box<dyn std::error::Error + Send + Sync>> {
         println!("event id: {:?}", event.payload.id);
+
+        // probing other regions is best effort, it never fails this region's healthcheck
+        let (result, _) = futures::join!(self.check(), self.probe_all());
+
+        // keep a summary of the latest result for the status query, pass or fail
+        let timestamp = Ksuid::new(None, None).timestamp_seconds();
+        self.dynamo_client.put_item()
+            .table_name(&self.table)
+            .item("region", dynamodb::model::AttributeValue::S("status".to_string()))
+            .item("id", dynamodb::model::AttributeValue::S(self.region.clone()))
+            .item("healthy", dynamodb::model::AttributeValue::Bool(result.is_ok()))
+            .item("timestamp", dynamodb::model::AttributeValue::N(timestamp.to_string()))
+            .send().await?;
+
+        result
+    }
+
+    async fn check(&self) -> Result<(),  Box<dyn std::error::Error + Send + Sync>> {
         let id = Ksuid::new(None, None);
 
         let req = appsync::GraphqlRequest{
@@ -67,6 +134,89 @@ impl Client {
 
         Ok(())
     }
+
+    async fn probe_all(&self) {
+        join_all(self.probes.iter().map(|probe| async move {
+            if let Err(e) = self.probe(probe).await {
+                println!("failed to record probe of {}: {:?}", probe.region, e);
+            }
+        })).await;
+    }
+
+    async fn probe(&self, probe: &Probe) -> Result<(),  Box<dyn std::error::Error + Send + Sync>> {
+        let started = Instant::now();
+        let req = appsync::GraphqlRequest{
+            query: PROBE_QUERY.to_string(),
+            variables: NoVariables{},
+        };
+
+        let reachable = match tokio::time::timeout(PROBE_TIMEOUT, probe.client.query::<RegionResponse, NoVariables>(req)).await {
+            Ok(Ok(response)) => match response.errors.iter().len() == 0 {
+                true => true,
+                false => {
+                    println!("probe of {} returned errors: {:?}", probe.region, response.errors);
+                    false
+                }
+            },
+            Ok(Err(e)) => {
+                println!("probe of {} failed: {:?}", probe.region, e);
+                false
+            },
+            Err(_) => {
+                println!("probe of {} timed out", probe.region);
+                false
+            }
+        };
+        let latency = started.elapsed().as_millis();
+
+        let timestamp = Ksuid::new(None, None).timestamp_seconds();
+        self.dynamo_client.put_item()
+            .table_name(&self.table)
+            .item("region", dynamodb::model::AttributeValue::S("probe".to_string()))
+            .item("id", dynamodb::model::AttributeValue::S(format!("{}#{}", probe.region, self.region)))
+            .item("probed_region", dynamodb::model::AttributeValue::S(probe.region.clone()))
+            .item("probing_region", dynamodb::model::AttributeValue::S(self.region.clone()))
+            .item("reachable", dynamodb::model::AttributeValue::Bool(reachable))
+            .item("latency", dynamodb::model::AttributeValue::N(latency.to_string()))
+            .item("timestamp", dynamodb::model::AttributeValue::N(timestamp.to_string()))
+            .item("ttl", dynamodb::model::AttributeValue::N((timestamp + PROBE_TTL_SECONDS).to_string()))
+            .send().await?;
+
+        let dimensions = vec![
+            cloudwatch::model::Dimension::builder().name("ProbedRegion").value(&probe.region).build(),
+            cloudwatch::model::Dimension::builder().name("ProbingRegion").value(&self.region).build(),
+        ];
+        let mut metrics = vec![
+            cloudwatch::model::MetricDatum::builder()
+                .metric_name("ProbeFailure")
+                .set_dimensions(Some(dimensions.clone()))
+                .value(if reachable { 0.0 } else { 1.0 })
+                .unit(cloudwatch::model::StandardUnit::Count)
+                .build(),
+            // same failure without the probing region, summed by the probed region's unreachable alarm
+            cloudwatch::model::MetricDatum::builder()
+                .metric_name("ProbeFailure")
+                .set_dimensions(Some(vec![dimensions[0].clone()]))
+                .value(if reachable { 0.0 } else { 1.0 })
+                .unit(cloudwatch::model::StandardUnit::Count)
+                .build(),
+        ];
+        if reachable {
+            metrics.push(cloudwatch::model::MetricDatum::builder()
+                .metric_name("ProbeLatency")
+                .set_dimensions(Some(dimensions))
+                .value(latency as f64)
+                .unit(cloudwatch::model::StandardUnit::Milliseconds)
+                .build());
+        }
+
+        probe.metric_client.put_metric_data()
+            .namespace(&self.namespace)
+            .set_metric_data(Some(metrics))
+            .send().await?;
+
+        Ok(())
+    }
 }
 
 async fn process_subscription(response: appsync::GraphqlResponse<HealthcheckResponse>) -> Result<Option<()>, Box<dyn std::error::Error + Send + Sync>> {
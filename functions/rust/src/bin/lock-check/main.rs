@@ -0,0 +1,167 @@
+use aws_lambda_events::event::cloudwatch_events::CloudWatchEvent;
+use aws_sdk_cloudwatch as cloudwatch;
+use aws_sdk_dynamodb as dynamodb;
+use futures::future::join_all;
+use lambda_runtime::{service_fn, Error, LambdaEvent};
+use std::time::{Duration, Instant};
+use svix_ksuid::*;
+
+struct Client {
+    region:        String,
+    table:         String,
+    namespace:     String,
+    timeout:       Duration,
+    dynamo_client: dynamodb::Client,
+    // every other region's replica, paired with its region name
+    replicas:      Vec<(String, dynamodb::Client)>,
+    metric_client: cloudwatch::Client,
+}
+
+const POLL_INTERVAL: Duration = Duration::from_millis(250);
+
+impl Client {
+    async fn new() -> Result<Self, Box<dyn std::error::Error + Send + Sync>> {
+        let config = aws_config::load_from_env().await;
+        let region = config.region().ok_or("No region in config")?.to_string();
+        let table = std::env::var("LOCK_TABLE")?;
+        let namespace = std::env::var("METRIC_NAMESPACE")?;
+        let timeout = Duration::from_secs(std::env::var("TIMEOUT_SECONDS")?.parse()?);
+
+        let replicas = std::env::var("LOCK_REGIONS")?
+            .split(",")
+            .filter(|replica| *replica != region)
+            .map(|replica| {
+                let regional_config = dynamodb::config::Builder::from(&config)
+                    .region(aws_types::region::Region::new(replica.to_string()))
+                    .build();
+                (replica.to_string(), dynamodb::Client::from_conf(regional_config))
+            })
+            .collect();
+
+        let dynamo_client = dynamodb::Client::new(&config);
+        let metric_client = cloudwatch::Client::new(&config);
+        Ok(Self { region, table, namespace, timeout, dynamo_client, replicas, metric_client })
+    }
+
+    async fn run(&self, event: LambdaEvent<CloudWatchEvent>) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        println!("event id: {:?}", event.payload.id);
+        let probe = Ksuid::new(None, None);
+        let probe_id = probe.to_base62();
+
+        // write the probe locally, then wait for it to show up everywhere else
+        self.dynamo_client.put_item()
+            .table_name(&self.table)
+            .item("process", dynamodb::model::AttributeValue::S(self.probe_key()))
+            .item("sk", dynamodb::model::AttributeValue::S("probe".to_string()))
+            .item("probe", dynamodb::model::AttributeValue::S(probe_id.clone()))
+            .item("ttl", dynamodb::model::AttributeValue::N((probe.timestamp_seconds() + 3600).to_string()))
+            .send().await?;
+        let written = Instant::now();
+
+        let results = join_all(self.replicas.iter().map(|(replica, client)| {
+            let probe_id = &probe_id;
+            async move { (replica, self.wait_for_probe(client, probe_id, written).await) }
+        })).await;
+
+        let mut metrics = Vec::new();
+        let mut max_lag = 0.0;
+        let mut failures = 0.0;
+        for (replica, result) in results {
+            let lag = match result {
+                Ok(lag) => {
+                    println!("probe replicated to {} in {}ms", replica, lag);
+                    lag
+                },
+                Err(e) => {
+                    println!("probe did not replicate to {}: {:?}", replica, e);
+                    failures += 1.0;
+                    self.timeout.as_millis() as f64
+                }
+            };
+
+            max_lag = f64::max(max_lag, lag);
+            metrics.push(self.datum("ReplicationLag", lag, cloudwatch::model::StandardUnit::Milliseconds, Some(replica)));
+        }
+
+        metrics.push(self.datum("MaxReplicationLag", max_lag, cloudwatch::model::StandardUnit::Milliseconds, None));
+        metrics.push(self.datum("ReplicationFailures", failures, cloudwatch::model::StandardUnit::Count, None));
+
+        self.metric_client.put_metric_data()
+            .namespace(&self.namespace)
+            .set_metric_data(Some(metrics))
+            .send().await?;
+
+        Ok(())
+    }
+
+    // polls a replica until it has the probe, returning the lag in milliseconds
+    async fn wait_for_probe(&self, client: &dynamodb::Client, probe: &str, written: Instant) -> Result<f64, Box<dyn std::error::Error + Send + Sync>> {
+        loop {
+            let result = client.get_item()
+                .table_name(&self.table)
+                .key("process", dynamodb::model::AttributeValue::S(self.probe_key()))
+                .key("sk", dynamodb::model::AttributeValue::S("probe".to_string()))
+                .send().await;
+
+            match result {
+                Ok(output) => {
+                    let replicated = output.item()
+                        .and_then(|item| item.get("probe"))
+                        .and_then(|value| value.as_s().ok())
+                        .map_or(false, |value| value == probe);
+
+                    if replicated {
+                        return Ok(written.elapsed().as_millis() as f64);
+                    }
+                },
+                // keep trying until the timeout, a flaky read isn't a failed replica
+                Err(e) => println!("failed to read probe: {:?}", e),
+            };
+
+            if written.elapsed() >= self.timeout {
+                return Err("timed out waiting for probe".into());
+            }
+            tokio::time::sleep(POLL_INTERVAL).await;
+        }
+    }
+
+    fn probe_key(&self) -> String {
+        format!("lock-check#{}", self.region)
+    }
+
+    fn datum(&self, name: &str, value: f64, unit: cloudwatch::model::StandardUnit, replica: Option<&String>) -> cloudwatch::model::MetricDatum {
+        let mut dimensions = vec![
+            cloudwatch::model::Dimension::builder().name("Region").value(&self.region).build(),
+        ];
+        if let Some(replica) = replica {
+            dimensions.push(cloudwatch::model::Dimension::builder().name("Replica").value(replica).build());
+        }
+
+        cloudwatch::model::MetricDatum::builder()
+            .metric_name(name)
+            .set_dimensions(Some(dimensions))
+            .value(value)
+            .unit(unit)
+            .build()
+    }
+}
+
+#[tokio::main]
+async fn main() -> Result<(), Error> {
+    tracing_subscriber::fmt()
+        .with_max_level(tracing::Level::WARN)
+        .with_target(false)
+        .without_time()
+        .init();
+
+    let client = Client::new().await?;
+    let client_ref = &client;
+
+    // Define a closure here that makes use of the shared client.
+    let handler_func_closure = move |event: LambdaEvent<CloudWatchEvent>| async move {
+        client_ref.run(event).await
+    };
+
+    lambda_runtime::run(service_fn(handler_func_closure)).await?;
+    Ok(())
+}
@@ -0,0 +1,81 @@
+use async_trait::async_trait;
+use lambda_runtime::{Error};
+use queue_processor::{Client, init, handler};
+use serde::{Serialize, Deserialize};
+use std::collections::BTreeMap;
+
+// same shape as any other queue entry, only the canary's synthetic users end up here
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct QueueItem {
+    user: String,
+    ip: String,
+    region: String,
+    mmr: i64,
+    join_time: i64,
+    coordinates: Coordinates,
+    queue: String,
+    #[serde(default)]
+    wait_count: i64,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+struct Coordinates {
+    latitude: f64,
+    longitude: f64,
+}
+
+impl queue_processor::Identifiable for QueueItem {
+    fn id(&self) -> String {
+        self.user.clone()
+    }
+
+    fn join_time(&self) -> i64 {
+        self.join_time
+    }
+}
+
+struct Processor {
+    client: Client<QueueItem>,
+}
+
+impl Processor {
+    async fn new() -> Result<Self, Error> {
+        match Client::new().await {
+            Ok(client) => Ok(Self { client }),
+            Err(e) => Err(e)
+        }
+    }
+}
+
+#[async_trait]
+impl queue_processor::Processor<QueueItem> for Processor {
+    fn client(&self) -> &Client<QueueItem> {
+        &self.client
+    }
+
+    // canary users share an ip and location, so the usual compatibility checks would never match them
+    // instead, pair up users that queued from the same region (each region's canary only waits on its own pair)
+    async fn make_matches(&self, items: Vec<QueueItem>) -> Result<Vec<Vec<QueueItem>>, Error> {
+        let mut by_region: BTreeMap<String, Vec<QueueItem>> = BTreeMap::new();
+        for item in items {
+            by_region.entry(item.region.clone()).or_default().push(item);
+        }
+
+        let mut matches: Vec<Vec<QueueItem>> = Vec::new();
+        for (_, mut queued) in by_region {
+            // items come off the index in join order, keep it that way
+            queued.sort_by_key(|item| item.join_time);
+            for pair in queued.chunks_exact(2) {
+                matches.push(pair.to_vec());
+            }
+        }
+        Ok(matches)
+    }
+}
+
+#[tokio::main]
+async fn main() -> Result<(), Error> {
+    init().await;
+    let processor = Processor::new().await.expect("Failed to init processor");
+    handler(&processor).await
+}
@@ -27,12 +27,20 @@ impl queue_processor::Identifiable for QueueItem {
     fn id(&self) -> String {
         self.user.clone()
     }
+
+    fn join_time(&self) -> i64 {
+        self.join_time
+    }
 }
 
 impl queue_processor::Identifiable for &QueueItem {
     fn id(&self) -> String {
         self.user.clone()
     }
+
+    fn join_time(&self) -> i64 {
+        self.join_time
+    }
 }
 
 struct Processor {
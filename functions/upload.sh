@@ -2,11 +2,16 @@
 
 # parse args
 deleteFlag=""
-while getopts "s:p:d" opt; do
+region="us-east-1"
+while getopts "b:r:p:d" opt; do
     case $opt in
-        s)
-            stack="$OPTARG"
-            echo "Using stack $stack"
+        b)
+            bucket_prefix="$OPTARG"
+            echo "Using bucket prefix $bucket_prefix"
+            ;;
+        r)
+            region="$OPTARG"
+            echo "Using main region $region"
             ;;
         p)
             prefix="$OPTARG"
@@ -27,8 +32,8 @@ done
 
 # ensure args exist
 shouldExit=0
-if [ -z "$stack" ]; then
-    echo 'Missing -s (name of stack containing relevant artifact parameters)'
+if [ -z "$bucket_prefix" ]; then
+    echo 'Missing -b (artifact bucket prefix, buckets are named <prefix>-<region>)'
     shouldExit=1
 fi
 if [ -z "$prefix" ]; then
@@ -37,30 +42,28 @@ if [ -z "$prefix" ]; then
 fi
 if [ $shouldExit -gt 0 ]; then exit 1; fi
 
-# get buckets/regions from stack set
-params=$(aws cloudformation describe-stacks --stack-name "$stack" | jq -r '.Stacks[0].Parameters')
-regions=()
-bucket_prefix=""
-for row in $(echo "${params}" | jq -r '.[] | @base64'); do
-    _jq() {
-        echo ${row} | base64 --decode | jq -r ${1}
-    }
+# sync zips to the main region, the bootstrap stack replicates them to the rest
+bucket="${bucket_prefix}-${region}"
+echo "Uploading to $bucket"
+aws s3 sync --exclude '*' --include '*.zip' $deleteFlag '.' "s3://$bucket/$prefix"
 
-    if [ "$(_jq '.ParameterKey')" = "StackSetBucketPrefix" ]; then
-        bucket_prefix="$(_jq '.ParameterValue')"
-    elif [ "$(_jq '.ParameterKey')" = "Regions" ]; then
-        IFS=',' read -ra regions <<< "$(_jq '.ParameterValue')"
-    fi
+# the deploy reads from every region, so don't finish until the copies exist
+for zip in *.zip; do
+    key="$prefix/$zip"
+    while true; do
+        status=$(aws s3api head-object --bucket "$bucket" --key "$key" | jq -r '.ReplicationStatus // "NONE"')
+        case $status in
+            COMPLETED|NONE)
+                break
+                ;;
+            FAILED)
+                echo "Replication failed for $key"
+                exit 1
+                ;;
+            *)
+                echo "Waiting for $key to replicate ($status)"
+                sleep 5
+                ;;
+        esac
+    done
 done
-
-# sync zips
-for region in "${regions[@]}"; do
-    bucket="${bucket_prefix}-${region}"
-    echo "Uploading to $bucket"
-
-    if [ -z "$deleteFlag" ]; then
-        aws s3 sync --exclude '*' --include '*.zip' '.' "s3://$bucket/$prefix"
-    else
-        aws s3 sync --exclude '*' --include '*.zip' --delete '.' "s3://$bucket/$prefix"
-    fi
-done
\ No newline at end of file
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
+)
+
+type IteratorAgeAlarmConfig struct {
+	// milliseconds
+	Threshold         *float64
+	EvaluationPeriods *float64
+	DatapointsToAlarm *float64
+	// sns topics notified on alarm/ok, keyed by region
+	Topics map[string]*string
+}
+
+// stream processing is what makes matches, so a stuck stream is an outage
+func (cfg IteratorAgeAlarmConfig) New(ctx TfContext, name *string, functionName *string, region string) CloudwatchMetricAlarm {
+	actions := []*string{}
+	if topic, ok := cfg.Topics[region]; ok {
+		actions = append(actions, topic)
+	}
+
+	return NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(ctx.Id), &CloudwatchMetricAlarmConfig{
+		Provider:           ctx.Provider,
+		AlarmName:          jsii.String(*name + "-iterator-age"),
+		AlarmDescription:   jsii.String(fmt.Sprintf("[%s/iterator-age/stream-behind] - stream processing is falling behind", *name)),
+		Namespace:          jsii.String("AWS/Lambda"),
+		MetricName:         jsii.String("IteratorAge"),
+		Statistic:          jsii.String("Maximum"),
+		ComparisonOperator: jsii.String("GreaterThanOrEqualToThreshold"),
+		Threshold:          cfg.Threshold,
+		EvaluationPeriods:  cfg.EvaluationPeriods,
+		Period:             jsii.Number(60),
+		DatapointsToAlarm:  cfg.DatapointsToAlarm,
+		TreatMissingData:   jsii.String("notBreaching"),
+		AlarmActions:       &actions,
+		OkActions:          &actions,
+		Dimensions: &map[string]*string{
+			"FunctionName": functionName,
+		},
+	})
+}
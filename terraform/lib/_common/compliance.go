@@ -0,0 +1,31 @@
+package common
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// synth time compliance checks, see lib/compliance
+const (
+	CHECK_ENCRYPTION   = "encryption"
+	CHECK_RETENTION    = "retention"
+	CHECK_WILDCARDS    = "wildcards"
+	CHECK_DEAD_LETTERS = "dead-letters"
+	CHECK_TAGS         = "tags"
+)
+
+const exemptMetadata = "compliance:exempt:"
+
+// records why a construct doesn't need to pass a check, next to the code that creates it
+func Exempt(construct constructs.IConstruct, check string, reason string) {
+	construct.Node().AddMetadata(jsii.String(exemptMetadata+check), jsii.String(reason), nil)
+}
+
+func IsExempt(construct constructs.IConstruct, check string) bool {
+	for _, entry := range *construct.Node().Metadata() {
+		if *entry.Type == exemptMetadata+check {
+			return true
+		}
+	}
+	return false
+}
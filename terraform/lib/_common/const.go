@@ -1,5 +1,40 @@
 package common
 
+// every stack deploys here, the other regions are copies
+const MAIN_REGION = "us-east-1"
+
 const (
 	QUEUE_UNRANKED_SOLO = "unranked-solo"
+	// hidden, only the healthcheck canary queues here
+	QUEUE_HEALTHCHECK = "healthcheck"
+
+	// gsi on every queue table, partitioned by queue + sorted by join time
+	QUEUE_SORT_INDEX = "queue_sort"
+)
+
+// component tag values, one per package that creates resources
+const (
+	COMPONENT_API           = "api"
+	COMPONENT_BASE          = "base"
+	COMPONENT_BOOTSTRAP     = "bootstrap"
+	COMPONENT_HEALTHCHECK   = "healthcheck"
+	COMPONENT_IP_LOOKUP     = "ip-lookup"
+	COMPONENT_LOCK_TABLE    = "lock-table"
+	COMPONENT_LOG_ARCHIVE   = "log-archive"
+	COMPONENT_MATCH_MAKE    = "match-make"
+	COMPONENT_MATCH_PUBLISH = "match-publish"
+	COMPONENT_MONITORING    = "monitoring"
 )
+
+var COMPONENTS = []string{
+	COMPONENT_API,
+	COMPONENT_BASE,
+	COMPONENT_BOOTSTRAP,
+	COMPONENT_HEALTHCHECK,
+	COMPONENT_IP_LOOKUP,
+	COMPONENT_LOCK_TABLE,
+	COMPONENT_LOG_ARCHIVE,
+	COMPONENT_MATCH_MAKE,
+	COMPONENT_MATCH_PUBLISH,
+	COMPONENT_MONITORING,
+}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchloggroup"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchlogsubscriptionfilter"
+)
+
+type LogGroupConfig struct {
+	Retention *float64
+	Encrypt   bool
+	KmsArns   MultiRegionId
+	// log archive destinations keyed by region, every group subscribes when set
+	Archive map[string]*string
+}
+
+func (cfg LogGroupConfig) New(ctx TfContext, name *string, region string) CloudwatchLogGroup {
+	logGroupConfig := &CloudwatchLogGroupConfig{
+		Provider:        ctx.Provider,
+		Name:            name,
+		RetentionInDays: cfg.Retention,
+	}
+
+	if cfg.Encrypt {
+		logGroupConfig.KmsKeyId = cfg.KmsArns.Region(region)
+	}
+
+	logGroup := NewCloudwatchLogGroup(ctx.Scope, jsii.String(ctx.Id), logGroupConfig)
+
+	if destination, ok := cfg.Archive[region]; ok {
+		NewCloudwatchLogSubscriptionFilter(ctx.Scope, jsii.String(ctx.Id+"_archive"), &CloudwatchLogSubscriptionFilterConfig{
+			Provider:       ctx.Provider,
+			Name:           jsii.String("archive"),
+			LogGroupName:   logGroup.Name(),
+			FilterPattern:  jsii.String(""),
+			DestinationArn: destination,
+		})
+	}
+
+	return logGroup
+}
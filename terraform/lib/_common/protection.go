@@ -0,0 +1,14 @@
+package common
+
+import (
+	"github.com/aws/jsii-runtime-go"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
+)
+
+// stops terraform from destroying (or replacing) the resource, turning it off is a deploy of its own
+// only terraform though, the pinned aws provider (4.39) predates dynamodb's deletion_protection_enabled
+func Protect(protect bool) *cdktf.TerraformResourceLifecycle {
+	return &cdktf.TerraformResourceLifecycle{
+		PreventDestroy: jsii.Bool(protect),
+	}
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicy"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/sqsqueue"
+)
+
+type FailureQueueConfig struct {
+	// sns topics notified on alarm/ok, keyed by region
+	Topics map[string]*string
+}
+
+// where a stream mapping sends the batches it gave up on (shard + sequence numbers, not the records themselves)
+func (cfg FailureQueueConfig) New(ctx TfContext, name *string, region string) SqsQueue {
+	queue := NewSqsQueue(ctx.Scope, jsii.String(ctx.Id), &SqsQueueConfig{
+		Provider:                ctx.Provider,
+		Name:                    failureQueueName(name),
+		MessageRetentionSeconds: jsii.Number(14 * 24 * 60 * 60),
+		SqsManagedSseEnabled:    jsii.Bool(true),
+	})
+
+	actions := []*string{}
+	if topic, ok := cfg.Topics[region]; ok {
+		actions = append(actions, topic)
+	}
+
+	// anything in here is a batch that was never processed
+	NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(ctx.Id+"_alarm"), &CloudwatchMetricAlarmConfig{
+		Provider:           ctx.Provider,
+		AlarmName:          jsii.String(*name + "-failures"),
+		AlarmDescription:   jsii.String(fmt.Sprintf("[%s/failures/records-dropped] - stream records were given up on, see the %s queue", *name, *queue.Name())),
+		Namespace:          jsii.String("AWS/SQS"),
+		MetricName:         jsii.String("ApproximateNumberOfMessagesVisible"),
+		Statistic:          jsii.String("Maximum"),
+		ComparisonOperator: jsii.String("GreaterThanOrEqualToThreshold"),
+		Threshold:          jsii.Number(1),
+		EvaluationPeriods:  jsii.Number(1),
+		Period:             jsii.Number(300),
+		TreatMissingData:   jsii.String("notBreaching"),
+		AlarmActions:       &actions,
+		OkActions:          &actions,
+		Dimensions: &map[string]*string{
+			"QueueName": queue.Name(),
+		},
+	})
+
+	return queue
+}
+
+// lambda roles are shared across regions, so this covers the function's queue in every one of them
+func (cfg FailureQueueConfig) AllowSend(ctx TfContext, role *string, name *string) {
+	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id), &IamRolePolicyConfig{
+		Provider: ctx.Provider,
+		Name:     jsii.String("failures"),
+		Role:     role,
+		Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("sqs:SendMessage"),
+					Resources: jsii.Strings("arn:aws:sqs:*:*:" + *failureQueueName(name)),
+				},
+			},
+		}).Json(),
+	})
+}
+
+func failureQueueName(name *string) *string {
+	return jsii.String(*name + "-failures")
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
+)
+
+// added to every taggable resource by the package that creates it, on top of the providers' default tags
+const TAG_COMPONENT = "component"
+
+type taggable interface {
+	TagsInput() *map[string]*string
+	SetTags(val *map[string]*string)
+}
+
+type componentTagAspect struct {
+	prefix    string
+	component string
+}
+
+// everything shares the stack as its scope, so resources are matched on the id prefix the package was given
+func TagComponent(ctx TfContext, component string) {
+	cdktf.Aspects_Of(ctx.Scope).Add(&componentTagAspect{prefix: ctx.Id, component: component})
+}
+
+func (aspect *componentTagAspect) Visit(node constructs.IConstruct) {
+	if _, ok := node.(cdktf.TerraformResource); !ok {
+		return
+	}
+	resource, ok := node.(taggable)
+	if !ok {
+		return
+	}
+
+	id := *node.Node().Id()
+	if id != aspect.prefix && !strings.HasPrefix(id, aspect.prefix+"_") && !strings.HasPrefix(id, aspect.prefix+"-") {
+		return
+	}
+
+	tags := map[string]*string{}
+	if resource.TagsInput() != nil {
+		for key, value := range *resource.TagsInput() {
+			tags[key] = value
+		}
+	}
+	// first match wins, a resource only belongs to one component
+	if _, ok := tags[TAG_COMPONENT]; ok {
+		return
+	}
+	tags[TAG_COMPONENT] = jsii.String(aspect.component)
+	resource.SetTags(&tags)
+}
@@ -38,21 +38,48 @@ func (cfg ObjectConfig) ToKey(key string) *string {
 }
 
 type MultiRegionId struct {
-	Primary  *string
-	Replicas map[string]*string
+	PrimaryRegion string
+	Primary       *string
+	Replicas      map[string]*string
 }
 
-func NewMultiRegionId(primary *string) MultiRegionId {
+func NewMultiRegionId(primaryRegion string, primary *string) MultiRegionId {
 	return MultiRegionId{
-		Primary:  primary,
-		Replicas: map[string]*string{},
+		PrimaryRegion: primaryRegion,
+		Primary:       primary,
+		Replicas:      map[string]*string{},
 	}
 }
 
+// a region without a replica is a bug, kms keys can't be used across regions
+func (id MultiRegionId) Region(region string) *string {
+	if region == id.PrimaryRegion {
+		return id.Primary
+	} else if replica, ok := id.Replicas[region]; ok {
+		return replica
+	}
+	panic("no replica in region " + region)
+}
+
 type LambdaIamConfig struct {
 	Path       *string
 	ExecPolicy *string
 	AssumeRole *string
+	// nil means roles are created without a boundary
+	PermissionsBoundary *string
+}
+
+type LambdaTracingConfig struct {
+	Enabled bool
+	// x-ray write policy, only attached when tracing is enabled
+	Policy *string
+}
+
+func (cfg LambdaTracingConfig) Mode() *string {
+	if cfg.Enabled {
+		return jsii.String("Active")
+	}
+	return jsii.String("PassThrough")
 }
 
 type ArnIdPair struct {
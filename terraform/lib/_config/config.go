@@ -1,23 +1,30 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 type Paths struct {
-	Stacks string
-	Vtl    string
-	Schema string
+	Stacks    string
+	Vtl       string
+	Schema    string
+	Resolvers string
+	Policies  string
 }
 
 type Config struct {
-	Stacks []Stack
-	Vtl    map[string]*string
-	Schema string
+	Stacks    []Stack
+	Vtl       map[string]*string
+	Schema    string
+	Resolvers []Resolver
+	// deployer policy documents by file name, see the bootstrap stack
+	Policies map[string]string
 }
 
 type Stack struct {
@@ -26,14 +33,30 @@ type Stack struct {
 }
 
 type StackVars struct {
-	Name      string        `json:"name"`
-	IamPath   string        `json:"iamPath"`
-	Regions   []string      `json:"regions"`
-	Backend   VarsBackend   `json:"backend"`
-	Artifacts VarsArtifacts `json:"artifacts"`
-	Domain    VarsDomain    `json:"domain"`
-	Alarms    VarsAlarms    `json:"alarms"`
-	Groups    VarsGroups    `json:"groups"`
+	Name                string                  `json:"name"`
+	IamPath             string                  `json:"iamPath"`
+	PermissionsBoundary VarsPermissionsBoundary `json:"permissionsBoundary"`
+	Regions             []string                `json:"regions"`
+	Account             VarsAccount             `json:"account"`
+	// on every resource, e.g. cost-center, owner, environment
+	Tags        map[string]string `json:"tags"`
+	Backend     VarsBackend       `json:"backend"`
+	Artifacts   VarsArtifacts     `json:"artifacts"`
+	Domain      VarsDomain        `json:"domain"`
+	Alarms      VarsAlarms        `json:"alarms"`
+	KeyAdmins   VarsKeyAdmins     `json:"keyAdmins"`
+	Cache       VarsCache         `json:"cache"`
+	Tracing     VarsTracing       `json:"tracing"`
+	Logging     VarsLogging       `json:"logging"`
+	IteratorAge VarsIteratorAge   `json:"iteratorAge"`
+	Healthcheck VarsHealthcheck   `json:"healthcheck"`
+	Metrics     VarsMetrics       `json:"metrics"`
+	Compliance  VarsCompliance    `json:"compliance"`
+	Kms         VarsKms           `json:"kms"`
+	Cost        VarsCost          `json:"cost"`
+	Backups     VarsBackups       `json:"backups"`
+	Protection  VarsProtection    `json:"protection"`
+	Bootstrap   VarsBootstrap     `json:"bootstrap"`
 }
 
 type VarsBackend struct {
@@ -43,6 +66,59 @@ type VarsBackend struct {
 	Table  string `json:"table"`
 }
 
+// adds a <stack>-bootstrap stack with everything the stack itself needs to exist first
+// the state bucket + table come from backend, artifact buckets from artifacts + regions
+type VarsBootstrap struct {
+	Enabled bool `json:"enabled"`
+	// some-user/some-repo, allowed to assume the deployer role
+	GithubRepo  string   `json:"githubRepo"`
+	Thumbprints []string `json:"thumbprints"`
+	// role + group names, both under iamPath
+	DeployerRole string `json:"deployerRole"`
+	AdminGroup   string `json:"adminGroup"`
+	// principals in other accounts, they assume the deployer role with account.externalId
+	TrustedPrincipals []string `json:"trustedPrincipals"`
+	// principals in other accounts that deploy lambdas from the artifact buckets
+	ArtifactReaders []string `json:"artifactReaders"`
+}
+
+func (bootstrap VarsBootstrap) validate(account VarsAccount, boundary VarsPermissionsBoundary) error {
+	if !bootstrap.Enabled {
+		return nil
+	} else if bootstrap.GithubRepo == "" {
+		return fmt.Errorf("githubRepo is required")
+	} else if len(bootstrap.TrustedPrincipals) > 0 && account.ExternalId == nil {
+		return fmt.Errorf("trustedPrincipals require account.externalId")
+	} else if boundary.ArnOrNil() == nil && !boundary.Create {
+		return fmt.Errorf("the deployer can only create roles with a permissionsBoundary")
+	}
+	return nil
+}
+
+// the account the stack deploys into, leaving it out uses whatever credentials terraform runs with
+type VarsAccount struct {
+	Id *string `json:"id"`
+	// assumed by every provider, the state backend keeps using the original credentials
+	DeployRoleArn *string `json:"deployRoleArn"`
+	ExternalId    *string `json:"externalId"`
+}
+
+func (account VarsAccount) validate() error {
+	if account.ExternalId != nil && account.DeployRoleArn == nil {
+		return fmt.Errorf("externalId needs a deployRoleArn")
+	}
+	if account.Id == nil || account.DeployRoleArn == nil {
+		return nil
+	}
+	// arn:aws:iam::<account>:role/...
+	if parts := strings.Split(*account.DeployRoleArn, ":"); len(parts) < 6 || parts[4] != *account.Id {
+		return fmt.Errorf("deployRoleArn %s isn't in account %s", *account.DeployRoleArn, *account.Id)
+	}
+	return nil
+}
+
+// lambda code buckets, named <bucketPrefix>-<region>
+// they can live in a shared services account as long as its bucket policies let this account read them, see bootstrap.artifactReaders
 type VarsArtifacts struct {
 	BucketPrefix string `json:"bucketPrefix"`
 	ObjectPrefix string `json:"objectPrefix"`
@@ -56,10 +132,269 @@ type VarsDomain struct {
 type VarsAlarms struct {
 	Enabled bool     `json:"enabled"`
 	SendTo  []string `json:"sendTo"`
+	// some subscribers (e.g. cross account) can't read cmk encrypted topics
+	Encrypt bool `json:"encrypt"`
+}
+
+// who administers the stack's kms keys, the account itself always can
+type VarsKeyAdmins struct {
+	// user/role arns
+	Principals []string `json:"principals"`
+	// arn patterns matched against the caller, e.g. "arn:aws:iam::*:role/aws-reserved/sso.amazonaws.com/*/AWSReservedSSO_Admin_*"
+	Patterns []string `json:"patterns"`
+	// iam group names, every user in them is an admin
+	Groups []string `json:"groups"`
+}
+
+// applies to the appsync api + every lambda log group
+type VarsLogging struct {
+	// appsync field log level: NONE, ERROR or ALL
+	FieldLogLevel         string `json:"fieldLogLevel"`
+	ExcludeVerboseContent bool   `json:"excludeVerboseContent"`
+	Retention             int    `json:"retention"`
+	Encrypt               bool   `json:"encrypt"`
+	// long term copy of every lambda/appsync log group
+	Archive VarsLogArchive `json:"archive"`
+}
+
+type VarsLogArchive struct {
+	Enabled bool `json:"enabled"`
+	// days before objects move to cheaper storage, and before they're deleted
+	InfrequentAccessDays int `json:"infrequentAccessDays"`
+	GlacierDays          int `json:"glacierDays"`
+	ExpirationDays       int `json:"expirationDays"`
+}
+
+// how often each region checks itself and how quickly a failing check takes it out of dns
+type VarsHealthcheck struct {
+	// eventbridge schedule expression
+	Schedule          string  `json:"schedule"`
+	Threshold         float64 `json:"threshold"`
+	Period            int     `json:"period"`
+	EvaluationPeriods int     `json:"evaluationPeriods"`
+	DatapointsToAlarm int     `json:"datapointsToAlarm"`
+	// breaching, notBreaching, ignore or missing
+	TreatMissingData string `json:"treatMissingData"`
+	// seconds a healthcheck record (and the block on new ones) lives
+	Ttl    int        `json:"ttl"`
+	Canary VarsCanary `json:"canary"`
+	Probe  VarsProbe  `json:"probe"`
+}
+
+func (healthcheck VarsHealthcheck) validate() error {
+	if !strings.HasPrefix(healthcheck.Schedule, "rate(") && !strings.HasPrefix(healthcheck.Schedule, "cron(") {
+		return fmt.Errorf("schedule '%s' must be a rate() or cron() expression", healthcheck.Schedule)
+	} else if healthcheck.Threshold <= 0 {
+		return fmt.Errorf("threshold must be positive")
+	} else if err := validateEvaluation(healthcheck.Period, healthcheck.EvaluationPeriods, healthcheck.DatapointsToAlarm); err != nil {
+		return err
+	} else if !missingData[healthcheck.TreatMissingData] {
+		return fmt.Errorf("treatMissingData must be breaching, notBreaching, ignore or missing")
+	} else if healthcheck.Ttl <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+	return nil
+}
+
+var missingData = map[string]bool{"breaching": true, "notBreaching": true, "ignore": true, "missing": true}
+
+// what cloudwatch accepts for a standard resolution alarm
+func validateEvaluation(period int, evaluationPeriods int, datapointsToAlarm int) error {
+	if period <= 0 || period%60 != 0 {
+		return fmt.Errorf("period must be a multiple of 60")
+	} else if evaluationPeriods < 1 || period*evaluationPeriods > 24*60*60 {
+		return fmt.Errorf("evaluationPeriods must be at least 1 and cover at most a day")
+	} else if datapointsToAlarm < 1 || datapointsToAlarm > evaluationPeriods {
+		return fmt.Errorf("datapointsToAlarm must be between 1 and evaluationPeriods")
+	}
+	return nil
+}
+
+type VarsProbe struct {
+	Enabled bool `json:"enabled"`
+	// other regions that must fail to reach a region before it alarms, 0 means a majority
+	Quorum            int `json:"quorum"`
+	EvaluationPeriods int `json:"evaluationPeriods"`
+	DatapointsToAlarm int `json:"datapointsToAlarm"`
+	// whether an unreachable region fails dns away from itself, needs at least 3 regions
+	AffectsHealth bool `json:"affectsHealth"`
+}
+
+func (probe VarsProbe) validate(regions int) error {
+	if !probe.Enabled {
+		return nil
+	} else if probe.Quorum < 0 || probe.Quorum > regions-1 {
+		return fmt.Errorf("quorum must be between 0 and the number of other regions")
+	} else if err := validateEvaluation(60, probe.EvaluationPeriods, probe.DatapointsToAlarm); err != nil {
+		return err
+	} else if probe.AffectsHealth && regions < 3 {
+		// with a single prober its own egress/dns trouble looks exactly like the probed region being down
+		return fmt.Errorf("affectsHealth needs at least 3 regions")
+	}
+	return nil
+}
+
+type VarsCanary struct {
+	Enabled bool `json:"enabled"`
+	// eventbridge schedule expression
+	Schedule string `json:"schedule"`
+	// seconds the synthetic users have to get matched
+	Deadline          int `json:"deadline"`
+	Period            int `json:"period"`
+	EvaluationPeriods int `json:"evaluationPeriods"`
+	DatapointsToAlarm int `json:"datapointsToAlarm"`
+}
+
+// custom metrics (lock replication, matches made, etc.)
+type VarsMetrics struct {
+	// defaults to the stack name
+	Namespace string `json:"namespace"`
+}
+
+// alarms on every dynamo stream mapping
+type VarsIteratorAge struct {
+	// milliseconds
+	Threshold         int `json:"threshold"`
+	EvaluationPeriods int `json:"evaluationPeriods"`
+	DatapointsToAlarm int `json:"datapointsToAlarm"`
+	// whether a stuck stream fails dns away from the region
+	AffectsHealth bool `json:"affectsHealth"`
+}
+
+// player pii and secrets get their own keys, operational state stays on the main key
+// turning it on for an existing stack re-encrypts the users + ip cache global tables and the ip-lookup secret
+type VarsKms struct {
+	SplitByDataClass bool `json:"splitByDataClass"`
+}
+
+// budget + anomaly detection on everything tagged with the stack's app tag, notifies alarms.sendTo
+type VarsCost struct {
+	Enabled      bool    `json:"enabled"`
+	MonthlyLimit float64 `json:"monthlyLimit"`
+	// percentages of the monthly limit
+	ActualThresholds   []float64 `json:"actualThresholds"`
+	ForecastThresholds []float64 `json:"forecastThresholds"`
+	AnomalyThreshold   float64   `json:"anomalyThreshold"`
+	// the app tag is activated account wide, leave this off for all but one stack per account
+	// aws only accepts it once the tag shows up in billing data, so a new account turns it on after its first deploy
+	ActivateTag bool `json:"activateTag"`
+}
+
+// aws backup plans by name, each stateful table opts into pitr and/or one plan
+type VarsBackups struct {
+	Plans  map[string]VarsBackupPlan `json:"plans"`
+	Tables VarsTableBackups          `json:"tables"`
+}
+
+type VarsBackupPlan struct {
+	// cron expression, utc
+	Schedule      string `json:"schedule"`
+	RetentionDays int    `json:"retentionDays"`
+	// regions recovery points are copied to, needs dynamodb advanced backups enabled on the account
+	CopyTo []string `json:"copyTo"`
+	// 0 means the same as retentionDays
+	CopyRetentionDays int `json:"copyRetentionDays"`
+}
+
+type VarsTableBackups struct {
+	Users   VarsTableBackup `json:"users"`
+	IpCache VarsTableBackup `json:"ipCache"`
+	Queues  VarsTableBackup `json:"queues"`
+	Matches VarsTableBackup `json:"matches"`
+	Locks   VarsTableBackup `json:"locks"`
+}
+
+type VarsTableBackup struct {
+	Pitr bool `json:"pitr"`
+	// empty means no scheduled backups
+	Plan string `json:"plan"`
+}
+
+func (backups VarsBackups) validate() error {
+	tables := map[string]VarsTableBackup{
+		"users":   backups.Tables.Users,
+		"ipCache": backups.Tables.IpCache,
+		"queues":  backups.Tables.Queues,
+		"matches": backups.Tables.Matches,
+		"locks":   backups.Tables.Locks,
+	}
+	for name, table := range tables {
+		if _, ok := backups.Plans[table.Plan]; table.Plan != "" && !ok {
+			return fmt.Errorf("%s table uses unknown backup plan %s", name, table.Plan)
+		}
+	}
+	return nil
+}
+
+// prevent_destroy on the stack's tables, keys and secrets
+// tearing a stack down means deploying it with protection off first
+type VarsProtection struct {
+	Enabled bool `json:"enabled"`
+	// has to be set to turn protection off, so a bad stack file can't drop it by accident
+	Ephemeral bool `json:"ephemeral"`
+}
+
+func (protection VarsProtection) validate() error {
+	if !protection.Enabled && !protection.Ephemeral {
+		return fmt.Errorf("protection can only be disabled on ephemeral stacks")
+	}
+	return nil
+}
+
+// synth time checks, each one is "error", "warn" or "off"
+type VarsCompliance struct {
+	Encryption  string `json:"encryption"`
+	Retention   string `json:"retention"`
+	Wildcards   string `json:"wildcards"`
+	DeadLetters string `json:"deadLetters"`
+	Tags        string `json:"tags"`
+	// relative to the stack name, e.g. "healthcheck"
+	UnencryptedTables []string `json:"unencryptedTables"`
+	RequiredTags      []string `json:"requiredTags"`
+}
+
+// applied to every role the stack creates, an existing arn wins over creating one
+type VarsPermissionsBoundary struct {
+	Arn    string `json:"arn"`
+	Create bool   `json:"create"`
+}
+
+// x-ray on the apis and every lambda
+type VarsTracing struct {
+	Enabled bool `json:"enabled"`
 }
 
-type VarsGroups struct {
-	InfraAdmin string `json:"infraAdmin"`
+// appsync server side caching, resolvers opt in individually via their manifest
+type VarsCache struct {
+	Enabled bool   `json:"enabled"`
+	Type    string `json:"type"`
+	Ttl     int    `json:"ttl"`
+	// hit rate (0-1) below which the cache alarm fires, 0 disables the alarm
+	MinHitRate float64 `json:"minHitRate"`
+}
+
+// declarative definition of an appsync resolver
+// pipeline resolvers list functions, unit resolvers name a data source + request template
+type Resolver struct {
+	Type       string   `json:"type"`
+	Field      string   `json:"field"`
+	Functions  []string `json:"functions"`
+	DataSource string   `json:"dataSource"`
+	Request    string   `json:"request"`
+	Response   string   `json:"response"`
+	// static values to stash (raw json)
+	Stash map[string]json.RawMessage `json:"stash"`
+	// stash key -> graphql argument name
+	StashArgs map[string]string `json:"stashArgs"`
+	// stash key -> name of a dynamo data source whose table name gets stashed
+	StashTables map[string]string `json:"stashTables"`
+	// only applied when the stack has caching enabled
+	Caching *ResolverCaching `json:"caching"`
+}
+
+type ResolverCaching struct {
+	Ttl  int      `json:"ttl"`
+	Keys []string `json:"keys"`
 }
 
 func (paths Paths) LoadConfig() (cfg Config, err error) {
@@ -69,6 +404,10 @@ func (paths Paths) LoadConfig() (cfg Config, err error) {
 		return cfg, fmt.Errorf("Failed to load vtl: %w", err)
 	} else if cfg.Schema, err = paths.loadSchema(); err != nil {
 		return cfg, fmt.Errorf("Failed to load schema: %w", err)
+	} else if cfg.Resolvers, err = paths.loadResolvers(); err != nil {
+		return cfg, fmt.Errorf("Failed to load resolvers: %w", err)
+	} else if cfg.Policies, err = paths.loadPolicies(); err != nil {
+		return cfg, fmt.Errorf("Failed to load policies: %w", err)
 	}
 	// fmt.Println(cfg.Vtl)
 	return cfg, nil
@@ -77,12 +416,27 @@ func (paths Paths) LoadConfig() (cfg Config, err error) {
 func (paths Paths) loadStacks() ([]Stack, error) {
 	stacks := []Stack{}
 	processFile := func(filename string, contents []byte) error {
-		stack := Stack{StackName: strings.TrimSuffix(filename, ".json")}
+		stack := Stack{StackName: strings.TrimSuffix(filename, ".json"), Vars: defaultVars()}
 		if err := json.Unmarshal(contents, &stack.Vars); err != nil {
 			return fmt.Errorf("Invalid json: %w", err)
 		}
 
-		// todo: validate vars
+		// todo: validate the rest of the vars
+		if err := stack.Vars.Backups.validate(); err != nil {
+			return fmt.Errorf("Invalid backups: %w", err)
+		} else if err := stack.Vars.Protection.validate(); err != nil {
+			return fmt.Errorf("Invalid protection: %w", err)
+		} else if err := stack.Vars.Healthcheck.validate(); err != nil {
+			return fmt.Errorf("Invalid healthcheck: %w", err)
+		} else if err := stack.Vars.Healthcheck.Probe.validate(len(stack.Vars.OrderedRegions())); err != nil {
+			return fmt.Errorf("Invalid healthcheck probe: %w", err)
+		} else if err := stack.Vars.validateTags(); err != nil {
+			return fmt.Errorf("Invalid tags: %w", err)
+		} else if err := stack.Vars.Account.validate(); err != nil {
+			return fmt.Errorf("Invalid account: %w", err)
+		} else if err := stack.Vars.Bootstrap.validate(stack.Vars.Account, stack.Vars.PermissionsBoundary); err != nil {
+			return fmt.Errorf("Invalid bootstrap: %w", err)
+		}
 		stacks = append(stacks, stack)
 		return nil
 	}
@@ -90,11 +444,109 @@ func (paths Paths) loadStacks() ([]Stack, error) {
 	return stacks, processDir(paths.Stacks, ".json", processFile)
 }
 
+// anything optional gets its default here, the stack file overrides it
+func defaultVars() StackVars {
+	return StackVars{
+		Alarms: VarsAlarms{
+			Encrypt: true,
+		},
+		Logging: VarsLogging{
+			FieldLogLevel:         "ERROR",
+			ExcludeVerboseContent: true,
+			Retention:             7,
+			Encrypt:               true,
+			Archive: VarsLogArchive{
+				Enabled:              false,
+				InfrequentAccessDays: 30,
+				GlacierDays:          90,
+				ExpirationDays:       365,
+			},
+		},
+		Cache: VarsCache{
+			Type: "SMALL",
+			Ttl:  60,
+		},
+		Healthcheck: VarsHealthcheck{
+			Schedule:          "rate(1 minute)",
+			Threshold:         1,
+			Period:            60,
+			EvaluationPeriods: 5,
+			DatapointsToAlarm: 3,
+			TreatMissingData:  "breaching",
+			Ttl:               6000,
+			Canary: VarsCanary{
+				Enabled:           true,
+				Schedule:          "rate(5 minutes)",
+				Deadline:          30,
+				Period:            300,
+				EvaluationPeriods: 3,
+				DatapointsToAlarm: 2,
+			},
+			Probe: VarsProbe{
+				Enabled:           true,
+				Quorum:            0,
+				EvaluationPeriods: 3,
+				DatapointsToAlarm: 2,
+				AffectsHealth:     false,
+			},
+		},
+		Cost: VarsCost{
+			Enabled:            false,
+			MonthlyLimit:       100,
+			ActualThresholds:   []float64{80, 100},
+			ForecastThresholds: []float64{100},
+			AnomalyThreshold:   20,
+			ActivateTag:        false,
+		},
+		Backups: VarsBackups{
+			Plans: map[string]VarsBackupPlan{},
+			// everything else is either cache or short lived
+			Tables: VarsTableBackups{
+				Users: VarsTableBackup{Pitr: true},
+			},
+		},
+		Bootstrap: VarsBootstrap{
+			// github's oidc intermediate
+			Thumbprints:  []string{"6938fd4d98bab03faadb97b34396831e3780aea1"},
+			DeployerRole: "infra-deployer",
+			AdminGroup:   "infra-admins",
+		},
+		Protection: VarsProtection{
+			Enabled: true,
+		},
+		Kms: VarsKms{
+			SplitByDataClass: false,
+		},
+		Compliance: VarsCompliance{
+			Encryption:  "error",
+			Retention:   "error",
+			Wildcards:   "error",
+			DeadLetters: "error",
+			Tags:        "error",
+			// random ids + health status, see the healthcheck table
+			UnencryptedTables: []string{"healthcheck"},
+			RequiredTags:      []string{"app", "region", "component"},
+		},
+		IteratorAge: VarsIteratorAge{
+			Threshold:         60000,
+			EvaluationPeriods: 5,
+			DatapointsToAlarm: 3,
+			AffectsHealth:     true,
+		},
+	}
+}
+
+func (cfg StackVars) MetricNamespace() string {
+	if cfg.Metrics.Namespace != "" {
+		return cfg.Metrics.Namespace
+	}
+	return cfg.Name
+}
+
 func (paths Paths) loadVtl() (map[string]*string, error) {
 	templates := map[string]*string{}
 	processFile := func(filename string, contents []byte) error {
-		// need to escape tf interpolation, which seems silly
-		s := strings.Replace(string(contents), "${", "$${", -1)
+		s := escapeInterpolation(string(contents))
 		templates[filename] = &s
 		return nil
 	}
@@ -102,6 +554,43 @@ func (paths Paths) loadVtl() (map[string]*string, error) {
 	return templates, processDir(paths.Vtl, ".vm", processFile)
 }
 
+func (paths Paths) loadResolvers() ([]Resolver, error) {
+	resolvers := []Resolver{}
+	processFile := func(filename string, contents []byte) error {
+		resolver := Resolver{}
+		if err := json.Unmarshal(contents, &resolver); err != nil {
+			return fmt.Errorf("Invalid json: %w", err)
+		} else if err = resolver.validate(); err != nil {
+			return fmt.Errorf("Invalid resolver: %w", err)
+		}
+
+		resolver.Request = escapeInterpolation(resolver.Request)
+		resolver.Response = escapeInterpolation(resolver.Response)
+		for key, value := range resolver.Stash {
+			resolver.Stash[key] = json.RawMessage(escapeInterpolation(string(value)))
+		}
+		resolvers = append(resolvers, resolver)
+		return nil
+	}
+
+	return resolvers, processDir(paths.Resolvers, ".json", processFile)
+}
+
+// compacted, managed policies have a size limit
+func (paths Paths) loadPolicies() (map[string]string, error) {
+	policies := map[string]string{}
+	processFile := func(filename string, contents []byte) error {
+		compact := bytes.Buffer{}
+		if err := json.Compact(&compact, contents); err != nil {
+			return fmt.Errorf("Invalid json: %w", err)
+		}
+		policies[strings.TrimSuffix(filename, ".json")] = compact.String()
+		return nil
+	}
+
+	return policies, processDir(paths.Policies, ".json", processFile)
+}
+
 func (paths Paths) loadSchema() (string, error) {
 	schema := ""
 	processFile := func(filename string, contents []byte) error {
@@ -112,6 +601,44 @@ func (paths Paths) loadSchema() (string, error) {
 	return schema, processDir(paths.Schema, ".graphql", processFile)
 }
 
+func (resolver Resolver) validate() error {
+	if resolver.Type == "" || resolver.Field == "" {
+		return fmt.Errorf("type and field are required")
+	} else if resolver.Response == "" {
+		return fmt.Errorf("response is required")
+	} else if resolver.IsPipeline() == (resolver.DataSource != "") {
+		return fmt.Errorf("exactly one of functions or dataSource must be set")
+	} else if !resolver.IsPipeline() && resolver.Request == "" {
+		return fmt.Errorf("unit resolvers require a request template")
+	} else if resolver.Caching != nil {
+		return resolver.Caching.validate()
+	}
+	return nil
+}
+
+func (caching ResolverCaching) validate() error {
+	if caching.Ttl < 1 || caching.Ttl > 3600 {
+		return fmt.Errorf("caching ttl must be between 1 and 3600 seconds")
+	}
+	for _, key := range caching.Keys {
+		if !strings.HasPrefix(key, "$context.arguments.") &&
+			!strings.HasPrefix(key, "$context.identity.") &&
+			!strings.HasPrefix(key, "$context.source.") {
+			return fmt.Errorf("caching key '%s' must reference $context.arguments, $context.identity or $context.source", key)
+		}
+	}
+	return nil
+}
+
+func (resolver Resolver) IsPipeline() bool {
+	return len(resolver.Functions) > 0
+}
+
+// need to escape tf interpolation, which seems silly
+func escapeInterpolation(s string) string {
+	return strings.Replace(s, "${", "$${", -1)
+}
+
 func processDir(dir string, suffix string, processFile func(filename string, contents []byte) error) error {
 	files, err := os.ReadDir(dir)
 	if err != nil {
@@ -132,6 +659,33 @@ func processDir(dir string, suffix string, processFile func(filename string, con
 	return nil
 }
 
+// set on every resource by the providers/packages themselves
+var automaticTags = map[string]bool{"app": true, "region": true, "component": true}
+
+// required tags that aren't automatic have to come from the stack, so a missing one fails before synth
+func (cfg StackVars) validateTags() error {
+	for key := range cfg.Tags {
+		if automaticTags[key] {
+			return fmt.Errorf("%s is set automatically and can't be overridden", key)
+		}
+	}
+	for _, key := range cfg.Compliance.RequiredTags {
+		if _, ok := cfg.Tags[key]; !ok && !automaticTags[key] {
+			return fmt.Errorf("required tag %s is missing", key)
+		}
+	}
+	return nil
+}
+
+func (cfg StackVars) TagKeys() []string {
+	keys := []string{}
+	for key := range cfg.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // this is important as lock tables need to be processed in order
 func (cfg StackVars) OrderedRegions() []string {
 	return append([]string{"us-east-1"}, cfg.Regions...)
@@ -147,3 +701,10 @@ func (domain VarsDomain) Fqdn() string {
 	}
 	return domain.Name
 }
+
+func (boundary VarsPermissionsBoundary) ArnOrNil() *string {
+	if boundary.Arn != "" {
+		return &boundary.Arn
+	}
+	return nil
+}
@@ -13,27 +13,87 @@ type api struct {
 }
 
 type ApiConfig struct {
-	Providers         common.Providers
-	Name              *string
-	Schema            string
-	Vtl               map[string]*string
-	IamPath           *string
-	KmsWritePolicy    *string
-	KmsArns           common.MultiRegionId
-	DomainName        *string
-	HostedZoneId      *string
-	Queues            ApiQueueConfig
+	Providers common.Providers
+	Name      *string
+	Schema    string
+	Vtl       map[string]*string
+	Resolvers []ApiResolverConfig
+	IamPath   *string
+	// nil means the appsync role is created without a boundary
+	PermissionsBoundary *string
+	// attached to the appsync role, one per key it writes through
+	KmsWritePolicies []*string
+	// the api's own tables (users, ip cache)
+	KmsArns             common.MultiRegionId
+	PointInTimeRecovery ApiPitrConfig
+	DeletionProtection  bool
+	DomainName          *string
+	HostedZoneId        *string
+	Queues              ApiQueueConfig
+	Cache               ApiCacheConfig
+	Tracing             bool
+	Logging             ApiLoggingConfig
+	// seconds a posted healthcheck blocks the next one
+	HealthcheckTtl    *float64
 	FunctionsIpLookup map[string]common.ArnIdPair
 	TablesHealthcheck map[string]common.ArnIdPair
-	AlarmsHealthCheck map[string]common.ArnIdPair
+	TablesLock        map[string]common.ArnIdPair
+	// any of these alarming fails dns away from the region
+	AlarmsRegionHealth []map[string]common.ArnIdPair
+	// sns topics notified when a region's health changes, keyed by region
+	TopicsRegionHealth map[string]*string
 }
 
 type ApiQueueConfig struct {
 	UnrankedSolo queue
+	Healthcheck  queue
 }
 
 func (queues ApiQueueConfig) toList() []queue {
-	return []queue{queues.UnrankedSolo}
+	return []queue{queues.UnrankedSolo, queues.Healthcheck}
+}
+
+// per table, applies to every replica
+type ApiPitrConfig struct {
+	Users   bool
+	IpCache bool
+}
+
+type ApiLoggingConfig struct {
+	FieldLogLevel         *string
+	ExcludeVerboseContent bool
+	Logs                  common.LogGroupConfig
+}
+
+type ApiCacheConfig struct {
+	Enabled bool
+	Type    *string
+	Ttl     *float64
+	// 0 disables the hit rate alarm
+	MinHitRate float64
+	// sns topics notified on alarm/ok, keyed by region
+	Topics map[string]*string
+}
+
+// pipeline resolvers list functions, unit resolvers name a data source + request template
+type ApiResolverConfig struct {
+	Type       string
+	Field      string
+	Functions  []string
+	DataSource string
+	Request    string
+	Response   string
+	// stash key -> vtl expression (usually a json literal)
+	Stash       map[string]string
+	StashArgs   map[string]string
+	StashTables map[string]string
+	// nil means the resolver isn't cached
+	Caching *ApiResolverCachingConfig
+}
+
+type ApiResolverCachingConfig struct {
+	Ttl  int
+	Keys []string
 }
 
 type queue interface {
@@ -42,19 +102,25 @@ type queue interface {
 }
 
 func (cfg ApiConfig) New(ctx common.TfContext) api {
+	common.TagComponent(ctx, common.COMPONENT_API)
+
 	tables := apiTablesConfig{
 		providers: cfg.Providers,
 		name:      cfg.Name,
 		kmsArns:   cfg.KmsArns,
+		pitr:      cfg.PointInTimeRecovery,
+		protect:   cfg.DeletionProtection,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_tables", ctx.Provider))
 
 	role := appsyncRoleConfig{
 		name:              jsii.String(*cfg.Name + "-appsync"),
 		path:              cfg.IamPath,
-		kmsWritePolicy:    cfg.KmsWritePolicy,
+		boundary:          cfg.PermissionsBoundary,
+		kmsWritePolicies:  cfg.KmsWritePolicies,
 		queues:            cfg.Queues.toList(),
 		functionsIpLookup: cfg.FunctionsIpLookup,
 		tablesHealthcheck: cfg.TablesHealthcheck,
+		tablesLock:        cfg.TablesLock,
 		tablesUser:        tables.userTableIds(),
 		tablesIpCache:     tables.ipCacheTableIds(),
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_appsync_role", ctx.Provider))
@@ -65,33 +131,40 @@ func (cfg ApiConfig) New(ctx common.TfContext) api {
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_cert", ctx.Provider))
 
 	appsyncApi := appsyncApiConfig{
-		providers:         cfg.Providers,
-		name:              cfg.Name,
-		schema:            cfg.Schema,
-		domainName:        cfg.DomainName,
-		hostedZone:        cfg.HostedZoneId,
-		role:              role.Arn(),
-		cert:              cert.Arn(),
-		certValidation:    cert.Validation,
-		queues:            cfg.Queues,
-		functionsIpLookup: cfg.FunctionsIpLookup,
-		tablesHealthcheck: cfg.TablesHealthcheck,
-		tablesUser:        tables.userTableIds(),
-		tablesIpCache:     tables.ipCacheTableIds(),
-		alarmsHealthCheck: cfg.AlarmsHealthCheck,
+		providers:          cfg.Providers,
+		name:               cfg.Name,
+		schema:             cfg.Schema,
+		domainName:         cfg.DomainName,
+		hostedZone:         cfg.HostedZoneId,
+		role:               role.Arn(),
+		cert:               cert.Arn(),
+		certValidation:     cert.Validation,
+		queues:             cfg.Queues,
+		functionsIpLookup:  cfg.FunctionsIpLookup,
+		tablesHealthcheck:  cfg.TablesHealthcheck,
+		tablesLock:         cfg.TablesLock,
+		tablesUser:         tables.userTableIds(),
+		tablesIpCache:      tables.ipCacheTableIds(),
+		alarmsRegionHealth: cfg.AlarmsRegionHealth,
+		topicsRegionHealth: cfg.TopicsRegionHealth,
+		cache:              cfg.Cache,
+		tracing:            cfg.Tracing,
+		logging:            cfg.Logging,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id, ctx.Provider))
 
 	appsyncFunctions := appsyncFunctionsConfig{
-		providers: cfg.Providers,
-		apis:      appsyncApi,
-		vtl:       cfg.Vtl,
+		providers:      cfg.Providers,
+		apis:           appsyncApi,
+		vtl:            cfg.Vtl,
+		healthcheckTtl: cfg.HealthcheckTtl,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_functions", ctx.Provider))
 
 	appsyncResolversConfig{
 		providers: cfg.Providers,
 		apis:      appsyncApi,
 		functions: appsyncFunctions,
-		vtl:       cfg.Vtl,
+		resolvers: cfg.Resolvers,
+		caching:   cfg.Cache.Enabled,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_resolvers", ctx.Provider))
 
 	return api{
@@ -101,3 +174,11 @@ func (cfg ApiConfig) New(ctx common.TfContext) api {
 		appsyncApi: appsyncApi,
 	}
 }
+
+func (app api) UserTableIds() map[string]common.ArnIdPair {
+	return app.tables.userTableIds()
+}
+
+func (app api) IpCacheTableIds() map[string]common.ArnIdPair {
+	return app.tables.ipCacheTableIds()
+}
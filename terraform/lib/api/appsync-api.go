@@ -1,14 +1,18 @@
 package api
 
 import (
+	"fmt"
+
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/acmcertificatevalidation"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/appsyncapicache"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/appsyncdatasource"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/appsyncdomainname"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/appsyncdomainnameapiassociation"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/appsyncgraphqlapi"
-	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/route53healthcheck"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchloggroup"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/route53record"
 	"github.com/hashicorp/terraform-cdk-go/cdktf"
 )
@@ -19,8 +23,13 @@ type appsyncApi struct {
 
 type appsyncApiInstance struct {
 	Api         AppsyncGraphqlApi
+	LogGroup    CloudwatchLogGroup
 	DataSources appsyncDataSources
 	DomainName  AppsyncDomainName
+	Health      appsyncHealth
+	// nil when caching is disabled
+	Cache      AppsyncApiCache
+	CacheAlarm CloudwatchMetricAlarm
 }
 
 type appsyncDataSources struct {
@@ -29,28 +38,35 @@ type appsyncDataSources struct {
 	IpCache     AppsyncDatasource
 	User        AppsyncDatasource
 	Healthcheck AppsyncDatasource
+	Lock        AppsyncDatasource
 	Queues      appsyncQueueDataSources
 }
 
 type appsyncQueueDataSources struct {
 	UnrankedSolo AppsyncDatasource
+	Healthcheck  AppsyncDatasource
 }
 
 type appsyncApiConfig struct {
-	providers         common.Providers
-	name              *string
-	schema            string
-	domainName        *string
-	hostedZone        *string
-	cert              *string
-	certValidation    AcmCertificateValidation
-	role              *string
-	queues            ApiQueueConfig
-	functionsIpLookup map[string]common.ArnIdPair
-	tablesHealthcheck map[string]common.ArnIdPair
-	tablesUser        map[string]common.ArnIdPair
-	tablesIpCache     map[string]common.ArnIdPair
-	alarmsHealthCheck map[string]common.ArnIdPair
+	providers          common.Providers
+	name               *string
+	schema             string
+	domainName         *string
+	hostedZone         *string
+	cert               *string
+	certValidation     AcmCertificateValidation
+	role               *string
+	queues             ApiQueueConfig
+	functionsIpLookup  map[string]common.ArnIdPair
+	tablesHealthcheck  map[string]common.ArnIdPair
+	tablesLock         map[string]common.ArnIdPair
+	tablesUser         map[string]common.ArnIdPair
+	tablesIpCache      map[string]common.ArnIdPair
+	alarmsRegionHealth []map[string]common.ArnIdPair
+	topicsRegionHealth map[string]*string
+	cache              ApiCacheConfig
+	tracing            bool
+	logging            ApiLoggingConfig
 }
 
 type appsyncApiInstanceConfig struct {
@@ -77,12 +93,22 @@ func (cfg appsyncApiInstanceConfig) new(ctx common.TfContext) appsyncApiInstance
 		Name:               cfg.name,
 		Schema:             jsii.String(cfg.schema),
 		AuthenticationType: jsii.String("AWS_IAM"), // note: this needs to change at some point
+		XrayEnabled:        jsii.Bool(cfg.tracing),
 		LogConfig: &AppsyncGraphqlApiLogConfig{
 			CloudwatchLogsRoleArn: cfg.role,
-			FieldLogLevel:         jsii.String("ALL"),
+			FieldLogLevel:         cfg.logging.FieldLogLevel,
+			ExcludeVerboseContent: jsii.Bool(cfg.logging.ExcludeVerboseContent),
 		},
 	})
 
+	// the group name depends on the api id, so it can only be created after the api
+	// the appsync role can't create log groups, so nothing is logged until this exists
+	logGroup := cfg.logging.Logs.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_logs", ctx.Provider),
+		jsii.String("/aws/appsync/apis/"+*api.Id()),
+		cfg.region,
+	)
+
 	domainName := NewAppsyncDomainName(ctx.Scope, jsii.String(ctx.Id+"_domain"), &AppsyncDomainNameConfig{
 		Provider:       ctx.Provider,
 		DomainName:     jsii.String(cfg.region + "." + *cfg.domainName),
@@ -96,14 +122,13 @@ func (cfg appsyncApiInstanceConfig) new(ctx common.TfContext) appsyncApiInstance
 		DomainName: domainName.DomainName(),
 	})
 
-	healthcheck := NewRoute53HealthCheck(ctx.Scope, jsii.String(ctx.Id+"_healthcheck"), &Route53HealthCheckConfig{
-		Provider:                     ctx.Provider,
-		ReferenceName:                jsii.String(*cfg.name + "-" + cfg.region),
-		Type:                         jsii.String("CLOUDWATCH_METRIC"),
-		CloudwatchAlarmName:          cfg.alarmsHealthCheck[cfg.region].Id,
-		CloudwatchAlarmRegion:        jsii.String(cfg.region),
-		InsufficientDataHealthStatus: jsii.String("Unhealthy"),
-	})
+	health := appsyncHealthConfig{
+		name:   cfg.name,
+		region: cfg.region,
+		apiId:  api.Id(),
+		alarms: cfg.alarmsRegionHealth,
+		topics: cfg.topicsRegionHealth,
+	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_health", ctx.Provider))
 
 	NewRoute53Record(ctx.Scope, jsii.String(ctx.Id+"_dns_global"), &Route53RecordConfig{
 		Provider:      ctx.Provider,
@@ -113,7 +138,7 @@ func (cfg appsyncApiInstanceConfig) new(ctx common.TfContext) appsyncApiInstance
 		Type:          jsii.String("CNAME"),
 		Ttl:           jsii.Number(300),
 		SetIdentifier: jsii.String(cfg.region),
-		HealthCheckId: healthcheck.Id(),
+		HealthCheckId: health.HealthCheck.Id(),
 		LatencyRoutingPolicy: &[]Route53RecordLatencyRoutingPolicy{
 			{
 				Region: jsii.String(cfg.region),
@@ -182,6 +207,16 @@ func (cfg appsyncApiInstanceConfig) new(ctx common.TfContext) appsyncApiInstance
 				TableName: cfg.tablesHealthcheck[cfg.region].Id,
 			},
 		}),
+		Lock: NewAppsyncDatasource(ctx.Scope, jsii.String(ctx.Id+"_datasource_lock"), &AppsyncDatasourceConfig{
+			Provider:       ctx.Provider,
+			ApiId:          api.Id(),
+			Name:           jsii.String("lock"),
+			Type:           jsii.String("AMAZON_DYNAMODB"),
+			ServiceRoleArn: cfg.role,
+			DynamodbConfig: &AppsyncDatasourceDynamodbConfig{
+				TableName: cfg.tablesLock[cfg.region].Id,
+			},
+		}),
 		Queues: appsyncQueueDataSources{
 			UnrankedSolo: NewAppsyncDatasource(ctx.Scope, jsii.String(ctx.Id+"_datasource_q_unranked_solo"), &AppsyncDatasourceConfig{
 				Provider:       ctx.Provider,
@@ -193,14 +228,120 @@ func (cfg appsyncApiInstanceConfig) new(ctx common.TfContext) appsyncApiInstance
 					TableName: cfg.queues.UnrankedSolo.Tables()[cfg.region].Id,
 				},
 			}),
+			Healthcheck: NewAppsyncDatasource(ctx.Scope, jsii.String(ctx.Id+"_datasource_q_healthcheck"), &AppsyncDatasourceConfig{
+				Provider:       ctx.Provider,
+				ApiId:          api.Id(),
+				Name:           jsii.String("q_healthcheck"),
+				Type:           jsii.String("AMAZON_DYNAMODB"),
+				ServiceRoleArn: cfg.role,
+				DynamodbConfig: &AppsyncDatasourceDynamodbConfig{
+					TableName: cfg.queues.Healthcheck.Tables()[cfg.region].Id,
+				},
+			}),
 		},
 	}
 
-	return appsyncApiInstance{
+	instance := appsyncApiInstance{
 		Api:         api,
+		LogGroup:    logGroup,
 		DomainName:  domainName,
+		Health:      health,
 		DataSources: dataSources,
 	}
+
+	if cfg.cache.Enabled {
+		instance.Cache, instance.CacheAlarm = cfg.newCache(ctx, api)
+	}
+
+	return instance
+}
+
+func (cfg appsyncApiInstanceConfig) newCache(ctx common.TfContext, api AppsyncGraphqlApi) (AppsyncApiCache, CloudwatchMetricAlarm) {
+	// resolvers opt in to caching individually
+	cache := NewAppsyncApiCache(ctx.Scope, jsii.String(ctx.Id+"_cache"), &AppsyncApiCacheConfig{
+		Provider:                 ctx.Provider,
+		ApiId:                    api.Id(),
+		ApiCachingBehavior:       jsii.String("PER_RESOLVER_CACHING"),
+		Type:                     cfg.cache.Type,
+		Ttl:                      cfg.cache.Ttl,
+		AtRestEncryptionEnabled:  jsii.Bool(true),
+		TransitEncryptionEnabled: jsii.Bool(true),
+	})
+
+	if cfg.cache.MinHitRate <= 0 {
+		return cache, nil
+	}
+
+	metric := func(id string, name string) CloudwatchMetricAlarmMetricQuery {
+		return CloudwatchMetricAlarmMetricQuery{
+			Id: jsii.String(id),
+			Metric: &CloudwatchMetricAlarmMetricQueryMetric{
+				Namespace:  jsii.String("AWS/AppSync"),
+				MetricName: jsii.String(name),
+				Stat:       jsii.String("Sum"),
+				Period:     jsii.Number(300),
+				Dimensions: &map[string]*string{
+					"GraphQLAPIId": api.Id(),
+				},
+			},
+		}
+	}
+
+	actions := []*string{}
+	if topic, ok := cfg.cache.Topics[cfg.region]; ok {
+		actions = append(actions, topic)
+	}
+
+	// hit rate only means something when there's traffic, so missing data is fine
+	alarm := NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(ctx.Id+"_cache_alarm"), &CloudwatchMetricAlarmConfig{
+		Provider:           ctx.Provider,
+		AlarmName:          jsii.String(*cfg.name + "-cache-hit-rate-" + cfg.region),
+		AlarmDescription:   jsii.String(fmt.Sprintf("[%s/cache/hit-rate] - appsync cache hit rate in %s is below %.2f", *cfg.name, cfg.region, cfg.cache.MinHitRate)),
+		ComparisonOperator: jsii.String("LessThanThreshold"),
+		Threshold:          jsii.Number(cfg.cache.MinHitRate),
+		EvaluationPeriods:  jsii.Number(3),
+		DatapointsToAlarm:  jsii.Number(3),
+		TreatMissingData:   jsii.String("notBreaching"),
+		AlarmActions:       &actions,
+		OkActions:          &actions,
+		MetricQuery: []CloudwatchMetricAlarmMetricQuery{
+			metric("hits", "CacheHit"),
+			metric("misses", "CacheMiss"),
+			{
+				Id:         jsii.String("hit_rate"),
+				Label:      jsii.String("cache hit rate"),
+				Expression: jsii.String("hits / (hits + misses)"),
+				ReturnData: jsii.Bool(true),
+			},
+		},
+	})
+
+	return cache, alarm
+}
+
+// data sources keyed by their appsync name, used to resolve references from the resolver manifest
+func (sources appsyncDataSources) byName() map[string]AppsyncDatasource {
+	result := map[string]AppsyncDatasource{}
+	for _, source := range []AppsyncDatasource{
+		sources.Noop,
+		sources.IpLookup,
+		sources.IpCache,
+		sources.User,
+		sources.Healthcheck,
+		sources.Lock,
+		sources.Queues.UnrankedSolo,
+		sources.Queues.Healthcheck,
+	} {
+		result[*source.NameInput()] = source
+	}
+	return result
+}
+
+// queue data sources keyed by queue name, the healthcheck queue is left out so it stays hidden from status
+func (sources appsyncQueueDataSources) byQueue() map[string]AppsyncDatasource {
+	return map[string]AppsyncDatasource{
+		common.QUEUE_UNRANKED_SOLO: sources.UnrankedSolo,
+	}
 }
 
 func (app appsyncApi) ApiIds() map[string]common.ArnIdPair {
@@ -208,3 +349,15 @@ func (app appsyncApi) ApiIds() map[string]common.ArnIdPair {
 		return common.ArnIdPair{Arn: instance.Api.Arn(), Id: instance.Api.Id()}
 	})
 }
+
+func (app appsyncApi) RegionHealthAlarmIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance appsyncApiInstance) common.ArnIdPair {
+		return common.ArnIdPair{Arn: instance.Health.Composite.Arn(), Id: instance.Health.Composite.AlarmName()}
+	})
+}
+
+func (app appsyncApi) LogGroupIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance appsyncApiInstance) common.ArnIdPair {
+		return common.ArnIdPair{Arn: instance.LogGroup.Arn(), Id: instance.LogGroup.Name()}
+	})
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/appsyncdatasource"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/appsyncfunction"
 )
 
@@ -11,20 +15,23 @@ type appsyncFunctions struct {
 }
 
 type appsyncFunctionsInstance struct {
-	CacheIp             AppsyncFunction
-	CheckIpCache        AppsyncFunction
-	EnqueueUnrankedSolo AppsyncFunction
-	GetUser             AppsyncFunction
-	HealthResponse      AppsyncFunction
-	LookupIp            AppsyncFunction
-	PostHealthcheck     AppsyncFunction
-	PublishMatch        AppsyncFunction
+	Functions map[string]AppsyncFunction
+}
+
+type appsyncFunctionDefinition struct {
+	name       string
+	dataSource AppsyncDatasource
+	// name of the vtl template pair (<template>.req.vm/<template>.resp.vm)
+	template string
+	// prepended to both templates, lets functions share a template with different stashed values
+	preamble string
 }
 
 type appsyncFunctionsConfig struct {
-	providers common.Providers
-	apis      appsyncApi
-	vtl       map[string]*string
+	providers      common.Providers
+	apis           appsyncApi
+	vtl            map[string]*string
+	healthcheckTtl *float64
 }
 
 type appsyncFunctionsInstanceConfig struct {
@@ -48,70 +55,49 @@ func (cfg appsyncFunctionsConfig) new(ctx common.TfContext) appsyncFunctions {
 }
 
 func (cfg appsyncFunctionsInstanceConfig) new(ctx common.TfContext) appsyncFunctionsInstance {
-	return appsyncFunctionsInstance{
-		CacheIp: NewAppsyncFunction(ctx.Scope, jsii.String(ctx.Id+"_cache_ip"), &AppsyncFunctionConfig{
-			Provider:                ctx.Provider,
-			Name:                    jsii.String("cache_ip"),
-			ApiId:                   cfg.api.Api.Id(),
-			DataSource:              cfg.api.DataSources.IpCache.Name(),
-			RequestMappingTemplate:  cfg.vtl["cache-ip.req.vm"],
-			ResponseMappingTemplate: cfg.vtl["cache-ip.resp.vm"],
-		}),
-		CheckIpCache: NewAppsyncFunction(ctx.Scope, jsii.String(ctx.Id+"_check_ip_cache"), &AppsyncFunctionConfig{
-			Provider:                ctx.Provider,
-			Name:                    jsii.String("check_ip_cache"),
-			ApiId:                   cfg.api.Api.Id(),
-			DataSource:              cfg.api.DataSources.IpCache.Name(),
-			RequestMappingTemplate:  cfg.vtl["check-ip-cache.req.vm"],
-			ResponseMappingTemplate: cfg.vtl["check-ip-cache.resp.vm"],
-		}),
-		EnqueueUnrankedSolo: NewAppsyncFunction(ctx.Scope, jsii.String(ctx.Id+"_enqueue_unranked_solo"), &AppsyncFunctionConfig{
-			Provider:                ctx.Provider,
-			Name:                    jsii.String("enqueue_unranked_solo"),
-			ApiId:                   cfg.api.Api.Id(),
-			DataSource:              cfg.api.DataSources.Queues.UnrankedSolo.Name(),
-			RequestMappingTemplate:  cfg.vtl["enqueue.req.vm"],
-			ResponseMappingTemplate: cfg.vtl["enqueue.resp.vm"],
-		}),
-		GetUser: NewAppsyncFunction(ctx.Scope, jsii.String(ctx.Id+"_get_user"), &AppsyncFunctionConfig{
-			Provider:                ctx.Provider,
-			Name:                    jsii.String("cache_ip"),
-			ApiId:                   cfg.api.Api.Id(),
-			DataSource:              cfg.api.DataSources.User.Name(),
-			RequestMappingTemplate:  cfg.vtl["get-user.req.vm"],
-			ResponseMappingTemplate: cfg.vtl["get-user.resp.vm"],
-		}),
-		HealthResponse: NewAppsyncFunction(ctx.Scope, jsii.String(ctx.Id+"_health_response"), &AppsyncFunctionConfig{
-			Provider:                ctx.Provider,
-			Name:                    jsii.String("health_response"),
-			ApiId:                   cfg.api.Api.Id(),
-			DataSource:              cfg.api.DataSources.Healthcheck.Name(),
-			RequestMappingTemplate:  cfg.vtl["healthcheck-response.req.vm"],
-			ResponseMappingTemplate: cfg.vtl["healthcheck-response.resp.vm"],
-		}),
-		LookupIp: NewAppsyncFunction(ctx.Scope, jsii.String(ctx.Id+"_lookup_ip"), &AppsyncFunctionConfig{
-			Provider:                ctx.Provider,
-			Name:                    jsii.String("lookup_ip"),
-			ApiId:                   cfg.api.Api.Id(),
-			DataSource:              cfg.api.DataSources.IpLookup.Name(),
-			RequestMappingTemplate:  cfg.vtl["lookup-ip.req.vm"],
-			ResponseMappingTemplate: cfg.vtl["lookup-ip.resp.vm"],
-		}),
-		PostHealthcheck: NewAppsyncFunction(ctx.Scope, jsii.String(ctx.Id+"_post_healthcheck"), &AppsyncFunctionConfig{
-			Provider:                ctx.Provider,
-			Name:                    jsii.String("post_healthcheck"),
-			ApiId:                   cfg.api.Api.Id(),
-			DataSource:              cfg.api.DataSources.Healthcheck.Name(),
-			RequestMappingTemplate:  cfg.vtl["post-healthcheck.req.vm"],
-			ResponseMappingTemplate: cfg.vtl["post-healthcheck.resp.vm"],
-		}),
-		PublishMatch: NewAppsyncFunction(ctx.Scope, jsii.String(ctx.Id+"_publish_match"), &AppsyncFunctionConfig{
+	dataSources := cfg.api.DataSources
+	definitions := []appsyncFunctionDefinition{
+		{name: "cache_ip", dataSource: dataSources.IpCache, template: "cache-ip"},
+		{name: "check_ip_cache", dataSource: dataSources.IpCache, template: "check-ip-cache"},
+		{name: "enqueue_healthcheck", dataSource: dataSources.Queues.Healthcheck, template: "enqueue"},
+		{name: "enqueue_unranked_solo", dataSource: dataSources.Queues.UnrankedSolo, template: "enqueue"},
+		{name: "get_queue_locks", dataSource: dataSources.Lock, template: "get-queue-locks"},
+		{name: "get_region_health", dataSource: dataSources.Healthcheck, template: "get-region-health"},
+		{name: "get_user", dataSource: dataSources.User, template: "get-user"},
+		{name: "health_response", dataSource: dataSources.Healthcheck, template: "healthcheck-response"},
+		{name: "lookup_ip", dataSource: dataSources.IpLookup, template: "lookup-ip"},
+		{name: "post_healthcheck", dataSource: dataSources.Healthcheck, template: "post-healthcheck", preamble: stashPut("healthcheck_ttl", fmt.Sprint(int(*cfg.healthcheckTtl))) + "\n"},
+		{name: "publish_match", dataSource: dataSources.Noop, template: "match"},
+	}
+
+	// one status function per queue, appsync function names can't contain dashes
+	queueSources := dataSources.Queues.byQueue()
+	for _, queue := range sortedKeys(queueSources) {
+		dataSource := queueSources[queue]
+		definitions = append(definitions, appsyncFunctionDefinition{
+			name:       "get_queue_status_" + strings.Replace(queue, "-", "_", -1),
+			dataSource: dataSource,
+			template:   "get-queue-status",
+			preamble: strings.Join([]string{
+				stashPut("queue_name", fmt.Sprintf(`"%s"`, queue)),
+				stashPut("queue_table", fmt.Sprintf(`"%s"`, *dataSource.DynamodbConfig().TableName())),
+				stashPut("queue_index", fmt.Sprintf(`"%s"`, common.QUEUE_SORT_INDEX)),
+				"",
+			}, "\n"),
+		})
+	}
+
+	functions := map[string]AppsyncFunction{}
+	for _, definition := range definitions {
+		functions[definition.name] = NewAppsyncFunction(ctx.Scope, jsii.String(ctx.Id+"_"+definition.name), &AppsyncFunctionConfig{
 			Provider:                ctx.Provider,
-			Name:                    jsii.String("publish_match"),
+			Name:                    jsii.String(definition.name),
 			ApiId:                   cfg.api.Api.Id(),
-			DataSource:              cfg.api.DataSources.Noop.Name(),
-			RequestMappingTemplate:  cfg.vtl["match.req.vm"],
-			ResponseMappingTemplate: cfg.vtl["match.resp.vm"],
-		}),
+			DataSource:              definition.dataSource.Name(),
+			RequestMappingTemplate:  jsii.String(definition.preamble + *cfg.vtl[definition.template+".req.vm"]),
+			ResponseMappingTemplate: jsii.String(definition.preamble + *cfg.vtl[definition.template+".resp.vm"]),
+		})
 	}
+
+	return appsyncFunctionsInstance{functions}
 }
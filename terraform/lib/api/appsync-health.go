@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchcompositealarm"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/route53healthcheck"
+)
+
+// server errors per minute before the api is considered broken
+const apiErrorThreshold = 5
+
+// average request latency (ms) before the api is considered broken
+const apiLatencyThreshold = 1000
+
+type appsyncHealth struct {
+	Alarms      []CloudwatchMetricAlarm
+	Composite   CloudwatchCompositeAlarm
+	HealthCheck Route53HealthCheck
+}
+
+type appsyncHealthConfig struct {
+	name   *string
+	region string
+	apiId  *string
+	// alarms from elsewhere that also mean the region is unhealthy, keyed by region
+	alarms []map[string]common.ArnIdPair
+	// notified by the composite, keyed by region
+	topics map[string]*string
+}
+
+func (cfg appsyncHealthConfig) new(ctx common.TfContext) appsyncHealth {
+	alarms := []CloudwatchMetricAlarm{
+		NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(ctx.Id+"_alarm_5xx"), &CloudwatchMetricAlarmConfig{
+			Provider:           ctx.Provider,
+			AlarmName:          jsii.String(*cfg.name + "-api-5xx"),
+			AlarmDescription:   jsii.String(fmt.Sprintf("[%s/api-5xx/system-down] - api is returning server errors", *cfg.name)),
+			Namespace:          jsii.String("AWS/AppSync"),
+			MetricName:         jsii.String("5XXError"),
+			Statistic:          jsii.String("Sum"),
+			ComparisonOperator: jsii.String("GreaterThanOrEqualToThreshold"),
+			Threshold:          jsii.Number(apiErrorThreshold),
+			EvaluationPeriods:  jsii.Number(5),
+			Period:             jsii.Number(60),
+			DatapointsToAlarm:  jsii.Number(3),
+			TreatMissingData:   jsii.String("notBreaching"),
+			Dimensions: &map[string]*string{
+				"GraphQLAPIId": cfg.apiId,
+			},
+		}),
+		NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(ctx.Id+"_alarm_latency"), &CloudwatchMetricAlarmConfig{
+			Provider:           ctx.Provider,
+			AlarmName:          jsii.String(*cfg.name + "-api-latency"),
+			AlarmDescription:   jsii.String(fmt.Sprintf("[%s/api-latency/system-slow] - api requests are taking too long", *cfg.name)),
+			Namespace:          jsii.String("AWS/AppSync"),
+			MetricName:         jsii.String("Latency"),
+			Statistic:          jsii.String("Average"),
+			ComparisonOperator: jsii.String("GreaterThanOrEqualToThreshold"),
+			Threshold:          jsii.Number(apiLatencyThreshold),
+			EvaluationPeriods:  jsii.Number(5),
+			Period:             jsii.Number(60),
+			DatapointsToAlarm:  jsii.Number(3),
+			TreatMissingData:   jsii.String("notBreaching"),
+			Dimensions: &map[string]*string{
+				"GraphQLAPIId": cfg.apiId,
+			},
+		}),
+	}
+
+	alarmNames := []*string{}
+	for _, alarm := range alarms {
+		alarmNames = append(alarmNames, alarm.AlarmName())
+	}
+	for _, alarmIds := range cfg.alarms {
+		alarmNames = append(alarmNames, alarmIds[cfg.region].Id)
+	}
+
+	rules := []string{}
+	for _, alarmName := range alarmNames {
+		rules = append(rules, fmt.Sprintf("ALARM(\"%s\")", *alarmName))
+	}
+
+	actions := []*string{}
+	if topic, ok := cfg.topics[cfg.region]; ok {
+		actions = append(actions, topic)
+	}
+
+	// one notification when the region goes down, instead of one per alarm
+	composite := NewCloudwatchCompositeAlarm(ctx.Scope, jsii.String(ctx.Id+"_composite"), &CloudwatchCompositeAlarmConfig{
+		Provider:         ctx.Provider,
+		AlarmName:        jsii.String(*cfg.name + "-region-health"),
+		AlarmDescription: jsii.String(fmt.Sprintf("[%s/region-health/region-down] - %s is unhealthy and should be failed away from", *cfg.name, cfg.region)),
+		AlarmRule:        jsii.String(strings.Join(rules, " OR ")),
+		AlarmActions:     &actions,
+		OkActions:        &actions,
+	})
+
+	// route53 can't watch composite alarms (or metric math), so dns gets its own copy of the rule:
+	// one child check per alarm, and the region is only healthy when every child is
+	children := []*string{}
+	for i, alarmName := range alarmNames {
+		children = append(children, NewRoute53HealthCheck(ctx.Scope, jsii.String(fmt.Sprintf("%s_healthcheck_%d", ctx.Id, i)), &Route53HealthCheckConfig{
+			Provider:                     ctx.Provider,
+			Type:                         jsii.String("CLOUDWATCH_METRIC"),
+			CloudwatchAlarmName:          alarmName,
+			CloudwatchAlarmRegion:        jsii.String(cfg.region),
+			InsufficientDataHealthStatus: jsii.String("Unhealthy"),
+			Tags: &map[string]*string{
+				"Name": alarmName,
+			},
+		}).Id())
+	}
+
+	healthCheck := NewRoute53HealthCheck(ctx.Scope, jsii.String(ctx.Id+"_healthcheck"), &Route53HealthCheckConfig{
+		Provider:             ctx.Provider,
+		ReferenceName:        jsii.String(*cfg.name + "-" + cfg.region),
+		Type:                 jsii.String("CALCULATED"),
+		ChildHealthchecks:    &children,
+		ChildHealthThreshold: jsii.Number(float64(len(children))),
+	})
+
+	return appsyncHealth{alarms, composite, healthCheck}
+}
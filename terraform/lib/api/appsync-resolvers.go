@@ -2,10 +2,12 @@ package api
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/appsyncdatasource"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/appsyncresolver"
 )
 
@@ -14,18 +16,16 @@ type appsyncResolvers struct {
 }
 
 type appsyncResolversInstance struct {
-	Query__region                       AppsyncResolver
-	Mutation__publishHealth             AppsyncResolver
-	Mutation__publishMatch              AppsyncResolver
-	Subscription__healthcheck           AppsyncResolver
-	Subscription__joinUnrankedSoloQueue AppsyncResolver
+	// keyed by <type>.<field>
+	Fields map[string]AppsyncResolver
 }
 
 type appsyncResolversConfig struct {
 	providers common.Providers
 	apis      appsyncApi
 	functions appsyncFunctions
-	vtl       map[string]*string
+	resolvers []ApiResolverConfig
+	caching   bool
 }
 
 type appsyncResolversInstanceConfig struct {
@@ -51,93 +51,101 @@ func (cfg appsyncResolversConfig) new(ctx common.TfContext) appsyncResolvers {
 }
 
 func (cfg appsyncResolversInstanceConfig) new(ctx common.TfContext) appsyncResolversInstance {
-	requestTemplate := `$util.quiet($ctx.stash.put("entry_time", $util.time.nowEpochSeconds()))` + "\n"
-	requestTemplate += `$util.quiet($ctx.stash.put("graphql", $ctx.info))` + "\n"
-	requestTemplate += fmt.Sprintf(`$util.quiet($ctx.stash.put("region", "%s"))`, cfg.region) + "\n"
-	requestTemplate += `{}`
+	dataSources := cfg.api.DataSources.byName()
+	fields := map[string]AppsyncResolver{}
 
-	return appsyncResolversInstance{
-		Query__region: NewAppsyncResolver(ctx.Scope, jsii.String(ctx.Id+"_Query_region"), &AppsyncResolverConfig{
+	for _, resolver := range cfg.resolvers {
+		resolverConfig := &AppsyncResolverConfig{
 			Provider:         ctx.Provider,
 			ApiId:            cfg.api.Api.Id(),
-			DataSource:       cfg.api.DataSources.Noop.Name(),
-			Type:             jsii.String("Query"),
-			Field:            jsii.String("region"),
-			RequestTemplate:  jsii.String(`{ "version": "2017-02-28", "payload": {} }`),
-			ResponseTemplate: jsii.String(fmt.Sprintf(`$util.toJson("%s")`, cfg.region)),
-		}),
-		Mutation__publishHealth: NewAppsyncResolver(ctx.Scope, jsii.String(ctx.Id+"_Mutation_publishHealth"), &AppsyncResolverConfig{
-			Provider:         ctx.Provider,
-			ApiId:            cfg.api.Api.Id(),
-			Kind:             jsii.String("PIPELINE"),
-			Type:             jsii.String("Mutation"),
-			Field:            jsii.String("publishHealth"),
-			RequestTemplate:  jsii.String(requestTemplate),
-			ResponseTemplate: jsii.String(`$util.toJson({ "id": $ctx.args.id })`),
-			PipelineConfig: &AppsyncResolverPipelineConfig{
-				Functions: jsii.Strings(
-					*cfg.fns.HealthResponse.FunctionId(),
-				),
-			},
-		}),
-		Mutation__publishMatch: NewAppsyncResolver(ctx.Scope, jsii.String(ctx.Id+"_Mutation_publishMatch"), &AppsyncResolverConfig{
-			Provider:         ctx.Provider,
-			ApiId:            cfg.api.Api.Id(),
-			Kind:             jsii.String("PIPELINE"),
-			Type:             jsii.String("Mutation"),
-			Field:            jsii.String("publishMatch"),
-			RequestTemplate:  jsii.String(requestTemplate),
-			ResponseTemplate: jsii.String(`$util.toJson($ctx.result)`),
-			PipelineConfig: &AppsyncResolverPipelineConfig{
-				Functions: jsii.Strings(
-					*cfg.fns.PublishMatch.FunctionId(),
-				),
-			},
-		}),
-		Subscription__healthcheck: NewAppsyncResolver(ctx.Scope, jsii.String(ctx.Id+"_Subscription_healthcheck"), &AppsyncResolverConfig{
-			Provider: ctx.Provider,
-			ApiId:    cfg.api.Api.Id(),
-			Kind:     jsii.String("PIPELINE"),
-			Type:     jsii.String("Subscription"),
-			Field:    jsii.String("healthcheck"),
-			RequestTemplate: jsii.String(strings.Join([]string{
-				// using an empty ip will get info about the requesting lambda's ip
-				`$util.quiet($ctx.stash.put("ip", ""))`,
-				fmt.Sprintf(`$util.quiet($ctx.stash.put("healthcheck_table", "%s"))`, *cfg.api.DataSources.Healthcheck.DynamodbConfig().TableName()),
-				requestTemplate,
-			}, "\n")),
-			ResponseTemplate: jsii.String("#return"),
-			PipelineConfig: &AppsyncResolverPipelineConfig{
-				Functions: jsii.Strings(
-					*cfg.fns.LookupIp.FunctionId(),
-					*cfg.fns.PostHealthcheck.FunctionId(),
-				),
-			},
-		}),
-		Subscription__joinUnrankedSoloQueue: NewAppsyncResolver(ctx.Scope, jsii.String(ctx.Id+"_Subscription_joinUnrankedSoloQueue"), &AppsyncResolverConfig{
-			Provider: ctx.Provider,
-			ApiId:    cfg.api.Api.Id(),
-			Kind:     jsii.String("PIPELINE"),
-			Type:     jsii.String("Subscription"),
-			Field:    jsii.String("joinUnrankedSoloQueue"),
-			RequestTemplate: jsii.String(strings.Join([]string{
-				`$util.quiet($ctx.stash.put("user", $ctx.args.userId))`,
-				`$util.quiet($ctx.stash.put("mmrKey", "unrankedSolo"))`,
-				// todo: consider removing dequeue entirely (no transaction, saves lot of cost)
-				`$util.quiet($ctx.stash.put("dequeue_tables", []))`,
-				fmt.Sprintf(`$util.quiet($ctx.stash.put("queue_table", "%s"))`, *cfg.api.DataSources.Queues.UnrankedSolo.DynamodbConfig().TableName()),
-				requestTemplate,
-			}, "\n")),
-			ResponseTemplate: jsii.String("#return"),
-			PipelineConfig: &AppsyncResolverPipelineConfig{
-				Functions: jsii.Strings(
-					*cfg.fns.CheckIpCache.FunctionId(),
-					*cfg.fns.LookupIp.FunctionId(),
-					*cfg.fns.CacheIp.FunctionId(),
-					*cfg.fns.GetUser.FunctionId(),
-					*cfg.fns.EnqueueUnrankedSolo.FunctionId(),
-				),
-			},
-		}),
+			Type:             jsii.String(resolver.Type),
+			Field:            jsii.String(resolver.Field),
+			RequestTemplate:  jsii.String(cfg.requestTemplate(resolver, dataSources)),
+			ResponseTemplate: jsii.String(resolver.Response),
+		}
+
+		if resolver.isPipeline() {
+			functionIds := []*string{}
+			for _, name := range resolver.Functions {
+				function, ok := cfg.fns.Functions[name]
+				if !ok {
+					panic(fmt.Sprintf("resolver %s.%s: unknown function '%s'", resolver.Type, resolver.Field, name))
+				}
+				functionIds = append(functionIds, function.FunctionId())
+			}
+
+			resolverConfig.Kind = jsii.String("PIPELINE")
+			resolverConfig.PipelineConfig = &AppsyncResolverPipelineConfig{
+				Functions: &functionIds,
+			}
+		} else {
+			resolverConfig.DataSource = lookupDataSource(resolver, dataSources, resolver.DataSource).Name()
+		}
+
+		// resolver caching config is rejected by appsync unless the api has a cache
+		if cfg.caching && resolver.Caching != nil {
+			resolverConfig.CachingConfig = &AppsyncResolverCachingConfig{
+				Ttl:         jsii.Number(float64(resolver.Caching.Ttl)),
+				CachingKeys: jsii.Strings(resolver.Caching.Keys...),
+			}
+		}
+
+		key := resolver.Type + "." + resolver.Field
+		fields[key] = NewAppsyncResolver(ctx.Scope, jsii.String(ctx.Id+"_"+resolver.Type+"_"+resolver.Field), resolverConfig)
+	}
+
+	return appsyncResolversInstance{fields}
+}
+
+// stashes common info + everything the manifest asks for before handing off to the request template
+func (cfg appsyncResolversInstanceConfig) requestTemplate(resolver ApiResolverConfig, dataSources map[string]AppsyncDatasource) string {
+	lines := []string{
+		stashPut("entry_time", `$util.time.nowEpochSeconds()`),
+		stashPut("graphql", `$ctx.info`),
+		stashPut("region", fmt.Sprintf(`"%s"`, cfg.region)),
+	}
+
+	for _, key := range sortedKeys(resolver.Stash) {
+		lines = append(lines, stashPut(key, resolver.Stash[key]))
+	}
+
+	for _, key := range sortedKeys(resolver.StashArgs) {
+		lines = append(lines, stashPut(key, "$ctx.args."+resolver.StashArgs[key]))
+	}
+
+	for _, key := range sortedKeys(resolver.StashTables) {
+		dataSource := lookupDataSource(resolver, dataSources, resolver.StashTables[key])
+		lines = append(lines, stashPut(key, fmt.Sprintf(`"%s"`, *dataSource.DynamodbConfig().TableName())))
+	}
+
+	if resolver.isPipeline() {
+		lines = append(lines, `{}`)
+	} else {
+		lines = append(lines, resolver.Request)
 	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (resolver ApiResolverConfig) isPipeline() bool {
+	return len(resolver.Functions) > 0
+}
+
+func lookupDataSource(resolver ApiResolverConfig, dataSources map[string]AppsyncDatasource, name string) AppsyncDatasource {
+	dataSource, ok := dataSources[name]
+	if !ok {
+		panic(fmt.Sprintf("resolver %s.%s: unknown data source '%s'", resolver.Type, resolver.Field, name))
+	}
+	return dataSource
+}
+
+func stashPut(key string, value string) string {
+	return fmt.Sprintf(`$util.quiet($ctx.stash.put("%s", %s))`, key, value)
+}
+
+// map iteration order is random, which would make for a different template every synth
+func sortedKeys[v any](m map[string]v) []string {
+	keys := common.Object[v](m).Keys()
+	sort.Strings(keys)
+	return keys
 }
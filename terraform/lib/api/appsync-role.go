@@ -1,9 +1,10 @@
 package api
 
 import (
+	"fmt"
+
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
-	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicy"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrole"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicy"
@@ -17,10 +18,12 @@ type appsyncRole struct {
 type appsyncRoleConfig struct {
 	name              *string
 	path              *string
-	kmsWritePolicy    *string
+	boundary          *string
+	kmsWritePolicies  []*string
 	queues            []queue
 	functionsIpLookup map[string]common.ArnIdPair
 	tablesHealthcheck map[string]common.ArnIdPair
+	tablesLock        map[string]common.ArnIdPair
 	tablesUser        map[string]common.ArnIdPair
 	tablesIpCache     map[string]common.ArnIdPair
 }
@@ -30,28 +33,26 @@ func (cfg appsyncRoleConfig) new(ctx common.TfContext) appsyncRole {
 		Service: "appsync.amazonaws.com",
 	}.Doc(common.SimpleContext(ctx.Scope, ctx.Id+"_assume_role", ctx.Provider))
 
-	logs := NewDataAwsIamPolicy(ctx.Scope, jsii.String(ctx.Id+"_logs_policy"), &DataAwsIamPolicyConfig{
-		Name: jsii.String("AWSAppSyncPushToCloudWatchLogs"),
-	})
-
 	role := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
-		Provider:         ctx.Provider,
-		Name:             cfg.name,
-		Path:             cfg.path,
-		AssumeRolePolicy: appsyncAssume.Json(),
-	})
-
-	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_logs"), &IamRolePolicyAttachmentConfig{
-		Provider:  ctx.Provider,
-		Role:      role.Name(),
-		PolicyArn: logs.Arn(),
+		Provider:            ctx.Provider,
+		Name:                cfg.name,
+		Path:                cfg.path,
+		AssumeRolePolicy:    appsyncAssume.Json(),
+		PermissionsBoundary: cfg.boundary,
 	})
 
-	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_kms"), &IamRolePolicyAttachmentConfig{
-		Provider:  ctx.Provider,
-		Role:      role.Name(),
-		PolicyArn: cfg.kmsWritePolicy,
-	})
+	for i, policy := range cfg.kmsWritePolicies {
+		// keep the original id for the first key so it isn't replaced
+		id := ctx.Id + "_kms"
+		if i > 0 {
+			id = fmt.Sprintf("%s_kms_%d", ctx.Id, i)
+		}
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(id), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      role.Name(),
+			PolicyArn: policy,
+		})
+	}
 
 	lambdaArns := common.ArnsToList(cfg.functionsIpLookup)
 	tableArns := common.ArnsToList(cfg.tablesHealthcheck)
@@ -61,6 +62,15 @@ func (cfg appsyncRoleConfig) new(ctx common.TfContext) appsyncRole {
 		tableArns = append(tableArns, common.ArnsToList(queue.Tables())...)
 	}
 
+	// read only access for the status query
+	queryArns := common.ArnsToList(cfg.tablesHealthcheck)
+	for _, queue := range cfg.queues {
+		for _, arn := range common.ArnsToList(queue.Tables()) {
+			queryArns = append(queryArns, jsii.String(*arn+"/index/"+common.QUEUE_SORT_INDEX))
+		}
+	}
+	lockArns := common.ArnsToList(cfg.tablesLock)
+
 	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_custom_policy"), &IamRolePolicyConfig{
 		Provider: ctx.Provider,
 		Name:     jsii.String("custom"),
@@ -77,6 +87,22 @@ func (cfg appsyncRoleConfig) new(ctx common.TfContext) appsyncRole {
 					Actions:   jsii.Strings("lambda:InvokeFunction"),
 					Resources: &lambdaArns,
 				},
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("dynamodb:Query"),
+					Resources: &queryArns,
+				},
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("dynamodb:GetItem", "dynamodb:BatchGetItem"),
+					Resources: &lockArns,
+				},
+				// no logs:CreateLogGroup, the api's log groups are created by terraform with retention + encryption
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("logs:CreateLogStream", "logs:PutLogEvents"),
+					Resources: jsii.Strings("arn:aws:logs:*:*:log-group:/aws/appsync/apis/*"),
+				},
 			},
 		}).Json(),
 	})
@@ -20,6 +20,9 @@ type apiTablesConfig struct {
 	providers common.Providers
 	name      *string
 	kmsArns   common.MultiRegionId
+	pitr      ApiPitrConfig
+	// users only, the ip cache rebuilds itself from lookups
+	protect bool
 }
 
 type tablesInstanceConfig struct {
@@ -30,14 +33,18 @@ type tablesInstanceConfig struct {
 }
 
 func (cfg apiTablesConfig) new(ctx common.TfContext) apiTables {
-	// create tables w/ replicas in each region
-	tableReplicas := []DynamodbTableReplica{}
-	for region, arn := range cfg.kmsArns.Replicas {
-		tableReplicas = append(tableReplicas, DynamodbTableReplica{
-			RegionName:    jsii.String(region),
-			KmsKeyArn:     arn,
-			PropagateTags: jsii.Bool(true),
-		})
+	// create tables w/ replicas in each region, pitr is set per table
+	tableReplicas := func(pitr bool) *[]DynamodbTableReplica {
+		replicas := []DynamodbTableReplica{}
+		for region, arn := range cfg.kmsArns.Replicas {
+			replicas = append(replicas, DynamodbTableReplica{
+				RegionName:          jsii.String(region),
+				KmsKeyArn:           arn,
+				PropagateTags:       jsii.Bool(true),
+				PointInTimeRecovery: jsii.Bool(pitr),
+			})
+		}
+		return &replicas
 	}
 
 	ipCacheTable := NewDynamodbTable(ctx.Scope, jsii.String(ctx.Id+"_ip_cache"), &DynamodbTableConfig{
@@ -48,11 +55,14 @@ func (cfg apiTablesConfig) new(ctx common.TfContext) apiTables {
 		HashKey:        jsii.String("ip"),
 		StreamEnabled:  jsii.Bool(true),
 		StreamViewType: jsii.String("NEW_AND_OLD_IMAGES"),
-		Replica:        &tableReplicas,
+		Replica:        tableReplicas(cfg.pitr.IpCache),
 		ServerSideEncryption: &DynamodbTableServerSideEncryption{
 			Enabled:   jsii.Bool(true),
 			KmsKeyArn: cfg.kmsArns.Primary,
 		},
+		PointInTimeRecovery: &DynamodbTablePointInTimeRecovery{
+			Enabled: jsii.Bool(cfg.pitr.IpCache),
+		},
 		Ttl: &DynamodbTableTtl{
 			Enabled:       jsii.Bool(true),
 			AttributeName: jsii.String("ttl"),
@@ -73,11 +83,15 @@ func (cfg apiTablesConfig) new(ctx common.TfContext) apiTables {
 		HashKey:        jsii.String("user"),
 		StreamEnabled:  jsii.Bool(true),
 		StreamViewType: jsii.String("NEW_AND_OLD_IMAGES"),
-		Replica:        &tableReplicas,
+		Replica:        tableReplicas(cfg.pitr.Users),
+		Lifecycle:      common.Protect(cfg.protect),
 		ServerSideEncryption: &DynamodbTableServerSideEncryption{
 			Enabled:   jsii.Bool(true),
 			KmsKeyArn: cfg.kmsArns.Primary,
 		},
+		PointInTimeRecovery: &DynamodbTablePointInTimeRecovery{
+			Enabled: jsii.Bool(cfg.pitr.Users),
+		},
 		Ttl: &DynamodbTableTtl{
 			Enabled:       jsii.Bool(true),
 			AttributeName: jsii.String("ttl"),
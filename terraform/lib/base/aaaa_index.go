@@ -8,54 +8,144 @@ import (
 type base struct {
 	Providers   providers
 	DataSources dataSources
-	KmsMain     keySet
+	Kms         keys
 	Policies    policies
+	// zero value unless enabled
+	Cost costs
+	// zero value unless there are any plans
+	Backup backups
 }
 
 type BaseConfig struct {
-	Regions        []string
-	Tags           *map[string]*string
-	AdminGroupName *string
+	Regions []string
+	Tags    *map[string]*string
+	// empty deploys into whichever account terraform's credentials belong to
+	Account AccountConfig
+	// user/role arns that administer the stack's keys
+	KeyAdmins []string
+	// matched against the caller's arn, e.g. sso permission set roles
+	KeyAdminPatterns []string
+	// optional, every user in these groups is a key admin
+	KeyAdminGroups []string
 	Name           *string
 	IamPath        *string
 	Domain         *string
+	EncryptTopics  bool
+	// arn of an existing boundary, otherwise one is created when requested
+	PermissionsBoundary       *string
+	CreatePermissionsBoundary bool
+	// separate keys for player pii and secrets, otherwise everything uses the main key
+	SplitKeys bool
+	// prevent_destroy on every key
+	DeletionProtection bool
+	Cost               CostConfig
+	Backup             BackupConfig
+}
+
+// every field is optional, the state backend isn't affected and stays wherever it's configured
+type AccountConfig struct {
+	Id            *string
+	DeployRoleArn *string
+	ExternalId    *string
 }
 
 func (cfg BaseConfig) New(ctx common.TfContext) base {
+	common.TagComponent(ctx, common.COMPONENT_BASE)
+
 	providers := providerConfig{
 		regions: cfg.Regions,
 		tags:    cfg.Tags,
 		name:    cfg.Name,
+		account: cfg.Account,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_providers", nil))
 
 	datasources := dataSourceConfig{
-		adminGroupName: cfg.AdminGroupName,
-		domain:         cfg.Domain,
+		adminGroupNames: cfg.KeyAdminGroups,
+		domain:          cfg.Domain,
+		accountId:       cfg.Account.Id,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_data", providers.Main))
 
-	kmsMain := keyConfig{
-		providers:   providers,
-		name:        jsii.String(*cfg.Name + "-main"),
-		description: jsii.String(*cfg.Name + " main key"),
-		accountId:   datasources.AccountId(),
-		keyAdmins:   *datasources.AdminUsers(),
-	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_kms_main", providers.Main))
+	key := keyConfig{
+		providers: providers,
+		accountId: datasources.AccountId(),
+		iamPath:   cfg.IamPath,
+		protect:   cfg.DeletionProtection,
+		keyAdmins: keyAdmins{
+			principals: *jsii.Strings(cfg.KeyAdmins...),
+			patterns:   cfg.KeyAdminPatterns,
+			groups:     datasources.AdminGroupUsers(),
+		},
+	}
+
+	// operational state keeps the original main key, so splitting never re-keys it
+	state := key
+	state.name = jsii.String(*cfg.Name + "-main")
+	state.description = jsii.String(*cfg.Name + " main key")
+	state.services = []string{"backup", "dynamodb", "s3"}
+	state.encryptLogs = true
+	state.encryptTopics = cfg.EncryptTopics
+	if !cfg.SplitKeys {
+		state.services = append(state.services, "secretsmanager")
+	}
+	kmsMain := state.new(common.SimpleContext(ctx.Scope, ctx.Id+"_kms_main", providers.Main))
+	kms := keys{State: kmsMain, Pii: kmsMain, Secrets: kmsMain}
+
+	if cfg.SplitKeys {
+		pii := key
+		pii.name = jsii.String(*cfg.Name + "-pii")
+		pii.description = jsii.String(*cfg.Name + " player pii key")
+		pii.services = []string{"backup", "dynamodb"}
+		kms.Pii = pii.new(common.SimpleContext(ctx.Scope, ctx.Id+"_kms_pii", providers.Main))
+
+		secrets := key
+		secrets.name = jsii.String(*cfg.Name + "-secrets")
+		secrets.description = jsii.String(*cfg.Name + " secrets key")
+		secrets.services = []string{"secretsmanager"}
+		kms.Secrets = secrets.new(common.SimpleContext(ctx.Scope, ctx.Id+"_kms_secrets", providers.Main))
+	}
 
 	policies := policyConfig{
-		kmsMain: kmsMain,
-		path:    cfg.IamPath,
-		name:    cfg.Name,
+		kms:            kms,
+		splitKeys:      cfg.SplitKeys,
+		path:           cfg.IamPath,
+		name:           cfg.Name,
+		boundaryArn:    cfg.PermissionsBoundary,
+		createBoundary: cfg.CreatePermissionsBoundary,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_policies", providers.Main))
 
 	resourceGroupConfig{
-		providers: providers,
-		name:      cfg.Name,
+		providers:  providers,
+		name:       cfg.Name,
+		components: common.COMPONENTS,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_rgs", providers.Main))
 
+	costs := costs{}
+	if cfg.Cost.Enabled {
+		costs = costConfig{
+			CostConfig: cfg.Cost,
+			name:       cfg.Name,
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_cost", providers.Main))
+	}
+
+	// recovery points hold player data, so the vaults use the pii key
+	backups := backups{}
+	if len(cfg.Backup.Plans) > 0 {
+		backups = backupConfig{
+			BackupConfig: cfg.Backup,
+			providers:    providers,
+			name:         cfg.Name,
+			iamPath:      cfg.IamPath,
+			boundary:     policies.PermissionsBoundary,
+			kmsArns:      kms.Pii.Arns(),
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_backup", providers.Main))
+	}
+
 	return base{
 		Providers:   providers,
 		DataSources: datasources,
-		KmsMain:     kmsMain,
+		Kms:         kms,
 		Policies:    policies,
+		Cost:        costs,
+		Backup:      backups,
 	}
 }
@@ -0,0 +1,141 @@
+package base
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/backupplan"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/backupselection"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/backupvault"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrole"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicyattachment"
+)
+
+type backups struct {
+	// one per region, plans back up into the main region's and copy into the others
+	Vaults map[string]BackupVault
+	Plans  map[string]BackupPlan
+	Role   IamRole
+}
+
+type BackupConfig struct {
+	// by name, tables pick one
+	Plans map[string]BackupScheduleConfig
+}
+
+type BackupScheduleConfig struct {
+	Schedule      *string
+	RetentionDays *float64
+	// regions the recovery points are copied to
+	// dynamodb only supports cross region copies with advanced backups enabled on the account
+	CopyTo            []string
+	CopyRetentionDays *float64
+}
+
+type backupConfig struct {
+	BackupConfig
+	providers providers
+	name      *string
+	iamPath   *string
+	boundary  *string
+	kmsArns   common.MultiRegionId
+}
+
+func (cfg backupConfig) new(ctx common.TfContext) backups {
+	vaults := map[string]BackupVault{}
+	for region, provider := range cfg.providers.All() {
+		vaults[region] = NewBackupVault(ctx.Scope, jsii.String(ctx.Id+"_vault_"+region), &BackupVaultConfig{
+			Provider:  provider,
+			Name:      jsii.String(*cfg.name + "-backups"),
+			KmsKeyArn: cfg.kmsArns.Region(region),
+		})
+	}
+
+	assume := common.ServiceAssumeRoleConfig{
+		Service: "backup.amazonaws.com",
+	}.Doc(common.SimpleContext(ctx.Scope, ctx.Id+"_assume_role", ctx.Provider))
+
+	role := NewIamRole(ctx.Scope, jsii.String(ctx.Id+"_role"), &IamRoleConfig{
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.name + "-backups"),
+		Path:                cfg.iamPath,
+		AssumeRolePolicy:    assume.Json(),
+		PermissionsBoundary: cfg.boundary,
+	})
+
+	// aws managed, arns are fixed
+	for _, policy := range []string{"AWSBackupServiceRolePolicyForBackup", "AWSBackupServiceRolePolicyForRestores"} {
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_role_"+policy), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      role.Name(),
+			PolicyArn: jsii.String("arn:aws:iam::aws:policy/service-role/" + policy),
+		})
+	}
+
+	// deterministic ids + rule order
+	names := common.Object[BackupScheduleConfig](cfg.Plans).Keys()
+	sort.Strings(names)
+
+	plans := map[string]BackupPlan{}
+	for _, name := range names {
+		plan := cfg.Plans[name]
+		copies := []BackupPlanRuleCopyAction{}
+		for _, region := range plan.CopyTo {
+			vault, ok := vaults[region]
+			if !ok {
+				panic(fmt.Sprintf("backup plan %s copies to %s, which the stack isn't deployed to", name, region))
+			}
+			copies = append(copies, BackupPlanRuleCopyAction{
+				DestinationVaultArn: vault.Arn(),
+				Lifecycle: &BackupPlanRuleCopyActionLifecycle{
+					DeleteAfter: plan.CopyRetentionDays,
+				},
+			})
+		}
+
+		plans[name] = NewBackupPlan(ctx.Scope, jsii.String(ctx.Id+"_plan_"+name), &BackupPlanConfig{
+			Provider: ctx.Provider,
+			Name:     jsii.String(*cfg.name + "-" + name),
+			Rule: []BackupPlanRule{
+				{
+					RuleName:        jsii.String(name),
+					TargetVaultName: vaults[common.MAIN_REGION].Name(),
+					Schedule:        plan.Schedule,
+					Lifecycle: &BackupPlanRuleLifecycle{
+						DeleteAfter: plan.RetentionDays,
+					},
+					CopyAction: copies,
+				},
+			},
+		})
+	}
+
+	return backups{vaults, plans, role}
+}
+
+// backs up the main region's copy of each table, replicas are covered by the plan's copy rules
+func (app backups) Select(ctx common.TfContext, plan string, tables ...map[string]common.ArnIdPair) {
+	if plan == "" {
+		return
+	}
+
+	backupPlan, ok := app.Plans[plan]
+	if !ok {
+		panic(fmt.Sprintf("unknown backup plan %s", plan))
+	}
+
+	resources := []*string{}
+	for _, table := range tables {
+		resources = append(resources, table[common.MAIN_REGION].Arn)
+	}
+
+	NewBackupSelection(ctx.Scope, jsii.String(ctx.Id), &BackupSelectionConfig{
+		Provider:   ctx.Provider,
+		Name:       jsii.String(ctx.Id),
+		PlanId:     backupPlan.Id(),
+		IamRoleArn: app.Role.Arn(),
+		Resources:  &resources,
+	})
+}
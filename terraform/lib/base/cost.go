@@ -0,0 +1,133 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/budgetsbudget"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/ceanomalymonitor"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/ceanomalysubscription"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cecostallocationtag"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
+)
+
+type costs struct {
+	Budget  BudgetsBudget
+	Monitor CeAnomalyMonitor
+}
+
+type CostConfig struct {
+	Enabled bool
+	// usd per month, across every region
+	MonthlyLimit *float64
+	// percentages of the limit
+	ActualThresholds   []float64
+	ForecastThresholds []float64
+	// usd of unexpected spend before an anomaly is reported
+	AnomalyThreshold *float64
+	// cost allocation tags are account wide, only one stack per account should activate them
+	ActivateTag bool
+	// activated alongside app, e.g. component or cost-center
+	AllocationTags []string
+	SendTo         []string
+}
+
+type costConfig struct {
+	CostConfig
+	name *string
+}
+
+// everything is filtered on the app tag the providers put on every resource
+func (cfg costConfig) new(ctx common.TfContext) costs {
+	dependsOn := []cdktf.ITerraformDependable{}
+	if cfg.ActivateTag {
+		dependsOn = append(dependsOn, NewCeCostAllocationTag(ctx.Scope, jsii.String(ctx.Id+"_tag"), &CeCostAllocationTagConfig{
+			Provider: ctx.Provider,
+			TagKey:   jsii.String("app"),
+			Status:   jsii.String("Active"),
+		}))
+
+		// only app is filtered on, the rest are for slicing cost explorer
+		for _, key := range cfg.AllocationTags {
+			NewCeCostAllocationTag(ctx.Scope, jsii.String(ctx.Id+"_tag_"+key), &CeCostAllocationTagConfig{
+				Provider: ctx.Provider,
+				TagKey:   jsii.String(key),
+				Status:   jsii.String("Active"),
+			})
+		}
+	}
+
+	// nobody to tell means the budget is only visible in the console
+	notifications := []BudgetsBudgetNotification{}
+	thresholds := map[string][]float64{"ACTUAL": cfg.ActualThresholds, "FORECASTED": cfg.ForecastThresholds}
+	for _, kind := range []string{"ACTUAL", "FORECASTED"} {
+		for _, threshold := range thresholds[kind] {
+			if len(cfg.SendTo) == 0 {
+				continue
+			}
+			notifications = append(notifications, BudgetsBudgetNotification{
+				ComparisonOperator:       jsii.String("GREATER_THAN"),
+				NotificationType:         jsii.String(kind),
+				Threshold:                jsii.Number(threshold),
+				ThresholdType:            jsii.String("PERCENTAGE"),
+				SubscriberEmailAddresses: jsii.Strings(cfg.SendTo...),
+			})
+		}
+	}
+
+	budget := NewBudgetsBudget(ctx.Scope, jsii.String(ctx.Id+"_budget"), &BudgetsBudgetConfig{
+		Provider:    ctx.Provider,
+		Name:        cfg.name,
+		BudgetType:  jsii.String("COST"),
+		TimeUnit:    jsii.String("MONTHLY"),
+		LimitAmount: jsii.String(fmt.Sprintf("%.2f", *cfg.MonthlyLimit)),
+		LimitUnit:   jsii.String("USD"),
+		CostFilter: []BudgetsBudgetCostFilter{
+			{
+				Name:   jsii.String("TagKeyValue"),
+				Values: jsii.Strings("user:app$" + *cfg.name),
+			},
+		},
+		Notification: notifications,
+		DependsOn:    &dependsOn,
+	})
+
+	spec, _ := json.Marshal(map[string]interface{}{
+		"Tags": map[string]interface{}{
+			"Key":          "app",
+			"Values":       []string{*cfg.name},
+			"MatchOptions": []string{"EQUALS"},
+		},
+	})
+
+	monitor := NewCeAnomalyMonitor(ctx.Scope, jsii.String(ctx.Id+"_anomaly_monitor"), &CeAnomalyMonitorConfig{
+		Provider:             ctx.Provider,
+		Name:                 cfg.name,
+		MonitorType:          jsii.String("CUSTOM"),
+		MonitorSpecification: jsii.String(string(spec)),
+		DependsOn:            &dependsOn,
+	})
+
+	if len(cfg.SendTo) > 0 {
+		subscribers := []CeAnomalySubscriptionSubscriber{}
+		for _, address := range cfg.SendTo {
+			subscribers = append(subscribers, CeAnomalySubscriptionSubscriber{
+				Type:    jsii.String("EMAIL"),
+				Address: jsii.String(address),
+			})
+		}
+
+		NewCeAnomalySubscription(ctx.Scope, jsii.String(ctx.Id+"_anomaly_subscription"), &CeAnomalySubscriptionConfig{
+			Provider:       ctx.Provider,
+			Name:           cfg.name,
+			Frequency:      jsii.String("DAILY"),
+			MonitorArnList: jsii.Strings(*monitor.Arn()),
+			Threshold:      cfg.AnomalyThreshold,
+			Subscriber:     subscribers,
+		})
+	}
+
+	return costs{budget, monitor}
+}
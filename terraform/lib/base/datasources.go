@@ -1,22 +1,28 @@
 package base
 
 import (
+	"fmt"
+
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawscalleridentity"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiamgroup"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsroute53zone"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
 )
 
 type dataSources struct {
 	DataAwsCallerIdentity
-	Admins     DataAwsIamGroup
-	HostedZone DataAwsRoute53Zone
+	// optional, their users are key admins
+	AdminGroups []DataAwsIamGroup
+	HostedZone  DataAwsRoute53Zone
 }
 
 type dataSourceConfig struct {
-	adminGroupName *string
-	domain         *string
+	adminGroupNames []string
+	domain          *string
+	// optional, fails the plan if the providers end up in any other account
+	accountId *string
 }
 
 func (cfg dataSourceConfig) new(ctx common.TfContext) dataSources {
@@ -24,10 +30,23 @@ func (cfg dataSourceConfig) new(ctx common.TfContext) dataSources {
 		Provider: ctx.Provider,
 	})
 
-	admins := NewDataAwsIamGroup(ctx.Scope, jsii.String(ctx.Id+"_admin_group"), &DataAwsIamGroupConfig{
-		Provider:  ctx.Provider,
-		GroupName: cfg.adminGroupName,
-	})
+	// the bindings don't expose postconditions
+	if cfg.accountId != nil {
+		caller.AddOverride(jsii.String("lifecycle.postcondition"), []map[string]string{
+			{
+				"condition":     fmt.Sprintf("${self.account_id == \"%s\"}", *cfg.accountId),
+				"error_message": fmt.Sprintf("Expected to deploy into account %s, check the deploy role.", *cfg.accountId),
+			},
+		})
+	}
+
+	admins := []DataAwsIamGroup{}
+	for i, name := range cfg.adminGroupNames {
+		admins = append(admins, NewDataAwsIamGroup(ctx.Scope, jsii.String(fmt.Sprintf("%s_admin_group_%d", ctx.Id, i)), &DataAwsIamGroupConfig{
+			Provider:  ctx.Provider,
+			GroupName: jsii.String(name),
+		}))
+	}
 
 	zone := NewDataAwsRoute53Zone(ctx.Scope, jsii.String(ctx.Id+"_zone"), &DataAwsRoute53ZoneConfig{
 		Provider: ctx.Provider,
@@ -37,12 +56,11 @@ func (cfg dataSourceConfig) new(ctx common.TfContext) dataSources {
 	return dataSources{caller, admins, zone}
 }
 
-func (data dataSources) AdminUsers() *[]*string {
-	list := []*string{}
-	users := data.Admins.Users()
-	i := float64(0)
-	for user := users.Get(jsii.Number(i)); user != nil && user.ComplexObjectIndex().(float64) >= i; i++ {
-		list = append(list, user.Arn())
+// user arns in each admin group, the list is only known once terraform reads the group
+func (data dataSources) AdminGroupUsers() []*[]*string {
+	users := []*[]*string{}
+	for _, group := range data.AdminGroups {
+		users = append(users, cdktf.Token_AsList(group.InterpolationForAttribute(jsii.String("users[*].arn")), nil))
 	}
-	return &list
+	return users
 }
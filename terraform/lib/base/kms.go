@@ -1,12 +1,17 @@
 package base
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/kmsalias"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/kmskey"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/kmsreplicakey"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/provider"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
 )
 
 type keySet struct {
@@ -29,8 +34,35 @@ type keyConfig struct {
 	name        *string
 	description *string
 	accountId   *string
-	// principal arns for users allowed admin on the key
-	keyAdmins []*string
+	// roles under this path can use the key, through the services below
+	iamPath   *string
+	keyAdmins keyAdmins
+	// services the key is used through, e.g. "dynamodb"
+	services []string
+	// encrypted log groups in this account
+	encryptLogs bool
+	// lets cloudwatch alarms publish to topics encrypted with the key
+	encryptTopics bool
+	// losing a key loses everything encrypted with it
+	protect bool
+}
+
+// principals allowed to administer the key, any of these can be empty
+type keyAdmins struct {
+	principals []*string
+	// matched against aws:PrincipalArn, principals can't be wildcarded
+	patterns []string
+	// one list of user arns per group
+	groups []*[]*string
+}
+
+// keys per data class, they all point at the main key unless the stack splits them
+type keys struct {
+	// operational state (queues, matches, locks), logs and alarm topics
+	State keySet
+	// users + ip cache
+	Pii     keySet
+	Secrets keySet
 }
 
 func (cfg keyConfig) new(ctx common.TfContext) keySet {
@@ -46,6 +78,7 @@ func (cfg keyConfig) new(ctx common.TfContext) keySet {
 		IsEnabled:             jsii.Bool(true),
 		KeyUsage:              jsii.String("ENCRYPT_DECRYPT"),
 		Policy:                policy.Json(),
+		Lifecycle:             common.Protect(cfg.protect),
 	})
 
 	primaryAlias := NewKmsAlias(ctx.Scope, jsii.String(ctx.Id+"_alias"), &KmsAliasConfig{
@@ -58,12 +91,12 @@ func (cfg keyConfig) new(ctx common.TfContext) keySet {
 	replicas := map[string]replicaKey{}
 
 	for region, provider := range cfg.providers.Copies {
-		replicas[region] = primaryKey.replica(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider))
+		replicas[region] = primaryKey.replica(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider), cfg.protect)
 	}
 	return keySet{primaryKey, replicas}
 }
 
-func (key primaryKey) replica(ctx common.TfContext) replicaKey {
+func (key primaryKey) replica(ctx common.TfContext, protect bool) replicaKey {
 	replica := NewKmsReplicaKey(ctx.Scope, jsii.String(ctx.Id), &KmsReplicaKeyConfig{
 		Provider:             ctx.Provider,
 		PrimaryKeyArn:        key.Key.Arn(),
@@ -71,6 +104,7 @@ func (key primaryKey) replica(ctx common.TfContext) replicaKey {
 		DeletionWindowInDays: jsii.Number(7),
 		Enabled:              jsii.Bool(true),
 		Policy:               key.Key.Policy(),
+		Lifecycle:            common.Protect(protect),
 	})
 
 	alias := NewKmsAlias(ctx.Scope, jsii.String(ctx.Id+"_alias"), &KmsAliasConfig{
@@ -82,42 +116,204 @@ func (key primaryKey) replica(ctx common.TfContext) replicaKey {
 }
 
 func (cfg keyConfig) policy(ctx common.TfContext) DataAwsIamPolicyDocument {
-	admins := append(cfg.keyAdmins, cfg.accountId)
-	doc := NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_policy"), &DataAwsIamPolicyDocumentConfig{
-		Statement: []DataAwsIamPolicyDocumentStatement{
+	// replicas share the primary's policy, so every region's services go here
+	regions := common.Object[AwsProvider](cfg.providers.All()).Keys()
+	sort.Strings(regions)
+	logsPrincipals := []*string{}
+	snsServices := []*string{}
+	viaServices := []*string{}
+	for _, region := range regions {
+		logsPrincipals = append(logsPrincipals, jsii.String("logs."+region+".amazonaws.com"))
+		snsServices = append(snsServices, jsii.String("sns."+region+".amazonaws.com"))
+		for _, service := range cfg.services {
+			viaServices = append(viaServices, jsii.String(service+"."+region+".amazonaws.com"))
+		}
+	}
+
+	cryptoActions := jsii.Strings("kms:CreateGrant", "kms:Decrypt", "kms:DescribeKey", "kms:Encrypt", "kms:GenerateDataKey*", "kms:ReEncrypt*")
+	viaService := DataAwsIamPolicyDocumentStatementCondition{
+		Test:     jsii.String("StringEquals"),
+		Variable: jsii.String("kms:ViaService"),
+		Values:   &viaServices,
+	}
+
+	// admins manage the key, but can't use it directly
+	// the account is always an admin, so the key can't be locked out
+	adminActions := jsii.Strings(
+		"kms:CancelKeyDeletion",
+		"kms:Create*",
+		"kms:Delete*",
+		"kms:Describe*",
+		"kms:Disable*",
+		"kms:Enable*",
+		"kms:Get*",
+		"kms:List*",
+		"kms:Put*",
+		"kms:ReplicateKey",
+		"kms:Revoke*",
+		"kms:ScheduleKeyDeletion",
+		"kms:TagResource",
+		"kms:UntagResource",
+		"kms:Update*",
+	)
+	statements := cfg.keyAdmins.statements("AllowKeyAdministration", cfg.accountId, true, nil, adminActions)
+	groups := cfg.keyAdmins.groupDocs(common.SimpleContext(ctx.Scope, ctx.Id+"_admin_groups", ctx.Provider), "AllowKeyAdministration", nil, adminActions)
+
+	// deploying tables/secrets has the service use the key on the admin's behalf
+	viaServiceConditions := []DataAwsIamPolicyDocumentStatementCondition{viaService}
+	statements = append(statements, cfg.keyAdmins.statements("AllowAdminsViaService", cfg.accountId, false, viaServiceConditions, cryptoActions)...)
+	groups = append(groups, cfg.keyAdmins.groupDocs(common.SimpleContext(ctx.Scope, ctx.Id+"_admin_groups_via_service", ctx.Provider), "AllowAdminsViaService", viaServiceConditions, cryptoActions)...)
+
+	// the stack's own roles, still subject to their iam policies
+	statements = append(statements, DataAwsIamPolicyDocumentStatement{
+		Sid:       jsii.String("AllowStackRolesViaService"),
+		Effect:    jsii.String("Allow"),
+		Actions:   cryptoActions,
+		Resources: jsii.Strings("*"),
+		Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
 			{
-				Sid:       jsii.String("AllowRoot"),
-				Effect:    jsii.String("Allow"),
-				Actions:   jsii.Strings("kms:*"),
-				Resources: jsii.Strings("*"),
-				Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
-					{
-						Type:        jsii.String("AWS"),
-						Identifiers: &admins,
-					},
-				},
+				Type:        jsii.String("AWS"),
+				Identifiers: jsii.Strings(*cfg.accountId),
+			},
+		},
+		Condition: []DataAwsIamPolicyDocumentStatementCondition{
+			viaService,
+			{
+				Test:     jsii.String("ArnLike"),
+				Variable: jsii.String("aws:PrincipalArn"),
+				Values:   jsii.Strings("arn:aws:iam::" + *cfg.accountId + ":role" + *cfg.iamPath + "*"),
 			},
-			// this is needed if the sns topic gets encrypted again. I imagine there should be a limiting condition
-			// {
-			// 	Sid:       jsii.String("AllowCloudwatchAlarms"),
-			// 	Effect:    jsii.String("Allow"),
-			// 	Actions:   jsii.Strings("kms:Decrypt", "kms:GenerateDataKey*"),
-			// 	Resources: jsii.Strings("*"),
-			// 	Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
-			// 		{
-			// 			Type:        jsii.String("Service"),
-			// 			Identifiers: jsii.Strings("cloudwatch.amazonaws.com", "sns.amazonaws.com"),
-			// 		},
-			// 	},
-			// },
 		},
 	})
 
-	return doc
+	// encrypted log groups, limited to groups in this account
+	if cfg.encryptLogs {
+		statements = append(statements, DataAwsIamPolicyDocumentStatement{
+			Sid:       jsii.String("AllowCloudwatchLogs"),
+			Effect:    jsii.String("Allow"),
+			Actions:   jsii.Strings("kms:Encrypt*", "kms:Decrypt*", "kms:ReEncrypt*", "kms:GenerateDataKey*", "kms:Describe*"),
+			Resources: jsii.Strings("*"),
+			Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+				{
+					Type:        jsii.String("Service"),
+					Identifiers: &logsPrincipals,
+				},
+			},
+			Condition: []DataAwsIamPolicyDocumentStatementCondition{
+				{
+					Test:     jsii.String("ArnLike"),
+					Variable: jsii.String("kms:EncryptionContext:aws:logs:arn"),
+					Values:   jsii.Strings("arn:aws:logs:*:" + *cfg.accountId + ":log-group:*"),
+				},
+			},
+		})
+	}
+
+	// alarms in this account publishing to encrypted topics, only through sns
+	if cfg.encryptTopics {
+		statements = append(statements, DataAwsIamPolicyDocumentStatement{
+			Sid:       jsii.String("AllowCloudwatchAlarms"),
+			Effect:    jsii.String("Allow"),
+			Actions:   jsii.Strings("kms:Decrypt", "kms:GenerateDataKey*"),
+			Resources: jsii.Strings("*"),
+			Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+				{
+					Type:        jsii.String("Service"),
+					Identifiers: jsii.Strings("cloudwatch.amazonaws.com"),
+				},
+			},
+			Condition: []DataAwsIamPolicyDocumentStatementCondition{
+				{
+					Test:     jsii.String("ArnLike"),
+					Variable: jsii.String("aws:SourceArn"),
+					Values:   jsii.Strings("arn:aws:cloudwatch:*:" + *cfg.accountId + ":alarm:*"),
+				},
+				{
+					Test:     jsii.String("StringEquals"),
+					Variable: jsii.String("kms:ViaService"),
+					Values:   &snsServices,
+				},
+			},
+		})
+	}
+
+	return NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_policy"), &DataAwsIamPolicyDocumentConfig{
+		Statement:             statements,
+		SourcePolicyDocuments: &groups,
+	})
+}
+
+// one statement per kind of admin, skipping any that weren't configured, groups are in groupDocs
+func (admins keyAdmins) statements(sid string, accountId *string, withAccount bool, conditions []DataAwsIamPolicyDocumentStatementCondition, actions *[]*string) []DataAwsIamPolicyDocumentStatement {
+	statement := func(suffix string, identifiers *[]*string, extra ...DataAwsIamPolicyDocumentStatementCondition) DataAwsIamPolicyDocumentStatement {
+		return DataAwsIamPolicyDocumentStatement{
+			Sid:       jsii.String(sid + suffix),
+			Effect:    jsii.String("Allow"),
+			Actions:   actions,
+			Resources: jsii.Strings("*"),
+			Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+				{
+					Type:        jsii.String("AWS"),
+					Identifiers: identifiers,
+				},
+			},
+			Condition: append(append([]DataAwsIamPolicyDocumentStatementCondition{}, conditions...), extra...),
+		}
+	}
+
+	statements := []DataAwsIamPolicyDocumentStatement{}
+	principals := admins.principals
+	if withAccount {
+		principals = append(append([]*string{}, principals...), accountId)
+	}
+	if len(principals) > 0 {
+		statements = append(statements, statement("", &principals))
+	}
+
+	if len(admins.patterns) > 0 {
+		statements = append(statements, statement("Patterns", jsii.Strings(*accountId), DataAwsIamPolicyDocumentStatementCondition{
+			Test:     jsii.String("ArnLike"),
+			Variable: jsii.String("aws:PrincipalArn"),
+			Values:   jsii.Strings(admins.patterns...),
+		}))
+	}
+
+	return statements
+}
+
+// groups are only read at plan time, and a statement without principals makes the whole key policy invalid
+// so each group gets its own document, with the statement left out while the group is empty
+func (admins keyAdmins) groupDocs(ctx common.TfContext, sid string, conditions []DataAwsIamPolicyDocumentStatementCondition, actions *[]*string) []*string {
+	condition := []map[string]interface{}{}
+	for _, c := range conditions {
+		condition = append(condition, map[string]interface{}{"test": c.Test, "variable": c.Variable, "values": c.Values})
+	}
+
+	docs := []*string{}
+	for i, users := range admins.groups {
+		// [0] with any users, [] without
+		nonEmpty := cdktf.TerraformIterator_FromList(cdktf.Fn_Range(jsii.Number(0), cdktf.Fn_Min(&[]*float64{cdktf.Fn_LengthOf(users), jsii.Number(1)}), jsii.Number(1)))
+
+		doc := NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(fmt.Sprintf("%s_%d", ctx.Id, i)), &DataAwsIamPolicyDocumentConfig{
+			Statement: nonEmpty.Dynamic(&map[string]interface{}{
+				"sid":       fmt.Sprintf("%sGroup%d", sid, i),
+				"effect":    "Allow",
+				"actions":   actions,
+				"resources": []string{"*"},
+				"principals": []map[string]interface{}{
+					{"type": "AWS", "identifiers": users},
+				},
+				"condition": condition,
+			}),
+		})
+		common.Exempt(doc, common.CHECK_WILDCARDS, "key policy statement, the dynamic block can't be read at synth time")
+		docs = append(docs, doc.Json())
+	}
+	return docs
 }
 
 func (keys keySet) Arns() common.MultiRegionId {
-	result := common.NewMultiRegionId(keys.Primary.Key.Arn())
+	result := common.NewMultiRegionId(common.MAIN_REGION, keys.Primary.Key.Arn())
 	for region, key := range keys.Replicas {
 		result.Replicas[region] = key.Key.Arn()
 	}
@@ -9,9 +9,19 @@ import (
 )
 
 type policies struct {
-	KmsMain          kmsPolicies
+	Kms              kmsPolicySet
 	LambdaExec       DataAwsIamPolicy
 	LambdaAssumeRole DataAwsIamPolicyDocument
+	XrayWrite        DataAwsIamPolicy
+	// arn applied to every role, nil when no boundary is configured
+	PermissionsBoundary *string
+}
+
+// read/write on each data class's key, shared when the keys aren't split
+type kmsPolicySet struct {
+	State   kmsPolicies
+	Pii     kmsPolicies
+	Secrets kmsPolicies
 }
 
 type kmsPolicies struct {
@@ -20,9 +30,13 @@ type kmsPolicies struct {
 }
 
 type policyConfig struct {
-	kmsMain keySet
-	path    *string
-	name    *string
+	kms       keys
+	splitKeys bool
+	path      *string
+	name      *string
+	// an existing boundary takes precedence over creating one
+	boundaryArn    *string
+	createBoundary bool
 }
 
 type kmsPoliciesConfig struct {
@@ -33,10 +47,25 @@ type kmsPoliciesConfig struct {
 
 func (cfg policyConfig) new(ctx common.TfContext) policies {
 	kmsMain := kmsPoliciesConfig{
-		keySet: cfg.kmsMain,
+		keySet: cfg.kms.State,
 		name:   *cfg.name + "-kms-main",
 		path:   cfg.path,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_kms_main", ctx.Provider))
+	kms := kmsPolicySet{State: kmsMain, Pii: kmsMain, Secrets: kmsMain}
+
+	if cfg.splitKeys {
+		kms.Pii = kmsPoliciesConfig{
+			keySet: cfg.kms.Pii,
+			name:   *cfg.name + "-kms-pii",
+			path:   cfg.path,
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_kms_pii", ctx.Provider))
+
+		kms.Secrets = kmsPoliciesConfig{
+			keySet: cfg.kms.Secrets,
+			name:   *cfg.name + "-kms-secrets",
+			path:   cfg.path,
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_kms_secrets", ctx.Provider))
+	}
 
 	lambdaExec := NewDataAwsIamPolicy(ctx.Scope, jsii.String(ctx.Id+"_lambda_exec"), &DataAwsIamPolicyConfig{
 		Name: jsii.String("AWSLambdaBasicExecutionRole"),
@@ -46,11 +75,131 @@ func (cfg policyConfig) new(ctx common.TfContext) policies {
 		Service: "lambda.amazonaws.com",
 	}.Doc(common.SimpleContext(ctx.Scope, ctx.Id+"_lambda_assume_role", ctx.Provider))
 
+	xrayWrite := NewDataAwsIamPolicy(ctx.Scope, jsii.String(ctx.Id+"_xray_write"), &DataAwsIamPolicyConfig{
+		Name: jsii.String("AWSXRayDaemonWriteAccess"),
+	})
+
+	boundary := cfg.boundaryArn
+	if boundary == nil && cfg.createBoundary {
+		boundary = cfg.boundary(common.SimpleContext(ctx.Scope, ctx.Id+"_boundary", ctx.Provider)).Arn()
+	}
+
 	return policies{
-		KmsMain:          kmsMain,
-		LambdaExec:       lambdaExec,
-		LambdaAssumeRole: lambdaAssume,
+		Kms:                 kms,
+		LambdaExec:          lambdaExec,
+		LambdaAssumeRole:    lambdaAssume,
+		XrayWrite:           xrayWrite,
+		PermissionsBoundary: boundary,
+	}
+}
+
+// caps what any role in the stack can be granted, covering every service the stack's roles use
+// the deployer can create it but not change or delete it, so updates have to be applied with admin credentials
+func (cfg policyConfig) boundary(ctx common.TfContext) IamPolicy {
+	doc := NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_doc"), &DataAwsIamPolicyDocumentConfig{
+		Statement: []DataAwsIamPolicyDocumentStatement{
+			{
+				Sid:    jsii.String("AllowStackServices"),
+				Effect: jsii.String("Allow"),
+				Actions: jsii.Strings(
+					"appsync:GraphQL",
+					"cloudwatch:DescribeAlarms",
+					"cloudwatch:PutMetricData",
+					"dynamodb:*Item",
+					"dynamodb:BatchGetItem",
+					"dynamodb:DescribeStream",
+					"dynamodb:GetRecords",
+					"dynamodb:GetShardIterator",
+					"dynamodb:ListStreams",
+					"dynamodb:Query",
+					"firehose:PutRecord*",
+					"kms:CreateGrant",
+					"kms:Decrypt",
+					"kms:Encrypt",
+					"kms:GenerateDataKey*",
+					"kms:GenerateDataPair*",
+					"kms:ReEncrypt*",
+					"lambda:InvokeFunction",
+					"logs:CreateLogGroup",
+					"logs:CreateLogStream",
+					"logs:DescribeLogStreams",
+					"logs:PutLogEvents",
+					"s3:AbortMultipartUpload",
+					"s3:GetBucketLocation",
+					"s3:GetObject",
+					"s3:ListBucket",
+					"s3:ListBucketMultipartUploads",
+					"s3:PutObject",
+					"secretsmanager:GetSecretValue",
+					"sns:Publish",
+					"sqs:SendMessage",
+					"xray:GetSampling*",
+					"xray:PutTelemetryRecords",
+					"xray:PutTraceSegments",
+				),
+				Resources: jsii.Strings("*"),
+			},
+			{
+				// what the aws backup service role's managed policies use, for the backup role only
+				// (the deployer can only create roles under this boundary, so it can't get one of its own)
+				Sid:    jsii.String("AllowBackupService"),
+				Effect: jsii.String("Allow"),
+				Actions: jsii.Strings(
+					"backup:*",
+					"dynamodb:BatchWriteItem",
+					"dynamodb:CreateBackup",
+					"dynamodb:DeleteBackup",
+					"dynamodb:DescribeBackup",
+					"dynamodb:DescribeTable",
+					"dynamodb:ListTagsOfResource",
+					"dynamodb:RestoreTableFromAwsBackup",
+					"dynamodb:RestoreTableFromBackup",
+					"dynamodb:Scan",
+					"dynamodb:StartAwsBackupJob",
+					"kms:DescribeKey",
+					"tag:GetResources",
+				),
+				Resources: jsii.Strings("*"),
+				Condition: []DataAwsIamPolicyDocumentStatementCondition{
+					{
+						Test:     jsii.String("ArnLike"),
+						Variable: jsii.String("aws:PrincipalArn"),
+						Values:   jsii.Strings("arn:aws:iam::*:role" + *cfg.path + *cfg.name + "-backups"),
+					},
+				},
+			},
+			{
+				// nothing in the stack manages iam at runtime, so a compromised role can't escalate
+				Sid:       jsii.String("DenyIam"),
+				Effect:    jsii.String("Deny"),
+				Actions:   jsii.Strings("iam:*"),
+				Resources: jsii.Strings("*"),
+			},
+		},
+	})
+
+	common.Exempt(doc, common.CHECK_WILDCARDS, "a boundary caps what roles can be granted, it grants nothing itself")
+
+	return NewIamPolicy(ctx.Scope, jsii.String(ctx.Id), &IamPolicyConfig{
+		Provider:    ctx.Provider,
+		Name:        jsii.String(*cfg.name + "-boundary"),
+		Path:        cfg.path,
+		Description: jsii.String(*cfg.name + " permissions boundary"),
+		Policy:      doc.Json(),
+	})
+}
+
+// write policies for the given data classes, without repeats when they share a key
+func (set kmsPolicySet) Writes(classes ...kmsPolicies) []*string {
+	arns := []*string{}
+	seen := map[IamPolicy]bool{}
+	for _, class := range classes {
+		if !seen[class.Write] {
+			seen[class.Write] = true
+			arns = append(arns, class.Write.Arn())
+		}
 	}
+	return arns
 }
 
 func (cfg kmsPoliciesConfig) new(ctx common.TfContext) kmsPolicies {
@@ -15,6 +15,7 @@ type providerConfig struct {
 	regions []string
 	tags    *map[string]*string
 	name    *string
+	account AccountConfig
 }
 
 func (cfg providerConfig) new(ctx common.TfContext) providers {
@@ -24,9 +25,11 @@ func (cfg providerConfig) new(ctx common.TfContext) providers {
 	}
 
 	// we _need_ us-east-1
-	region := "us-east-1"
+	region := common.MAIN_REGION
 	main := NewAwsProvider(ctx.Scope, jsii.String(ctx.Id+"_"+region), &AwsProviderConfig{
-		Region: jsii.String(region),
+		Region:            jsii.String(region),
+		AssumeRole:        cfg.assumeRole(),
+		AllowedAccountIds: cfg.allowedAccountIds(),
 		DefaultTags: &AwsProviderDefaultTags{
 			Tags: cfg.getTags(region),
 		},
@@ -36,8 +39,10 @@ func (cfg providerConfig) new(ctx common.TfContext) providers {
 	copies := map[string]AwsProvider{}
 	for _, region := range cfg.regions {
 		copies[region] = NewAwsProvider(ctx.Scope, jsii.String(ctx.Id+"_"+region), &AwsProviderConfig{
-			Region: jsii.String(region),
-			Alias:  jsii.String(region),
+			Region:            jsii.String(region),
+			Alias:             jsii.String(region),
+			AssumeRole:        cfg.assumeRole(),
+			AllowedAccountIds: cfg.allowedAccountIds(),
 			DefaultTags: &AwsProviderDefaultTags{
 				Tags: cfg.getTags(region),
 			},
@@ -55,9 +60,29 @@ func (cfg providerConfig) getTags(region string) *map[string]*string {
 	return &m
 }
 
+// nil deploys with whatever credentials terraform was run with
+func (cfg providerConfig) assumeRole() *AwsProviderAssumeRole {
+	if cfg.account.DeployRoleArn == nil {
+		return nil
+	}
+	return &AwsProviderAssumeRole{
+		RoleArn:     cfg.account.DeployRoleArn,
+		ExternalId:  cfg.account.ExternalId,
+		SessionName: jsii.String(*cfg.name + "-deploy"),
+	}
+}
+
+// the provider refuses to plan against any other account
+func (cfg providerConfig) allowedAccountIds() *[]*string {
+	if cfg.account.Id == nil {
+		return nil
+	}
+	return &[]*string{cfg.account.Id}
+}
+
 func (p providers) All() map[string]AwsProvider {
 	m := map[string]AwsProvider{}
-	m["us-east-1"] = p.Main
+	m[common.MAIN_REGION] = p.Main
 	for region, provider := range p.Copies {
 		m[region] = provider
 	}
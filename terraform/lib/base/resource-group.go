@@ -2,6 +2,7 @@ package base
 
 import (
 	"encoding/json"
+	"sort"
 
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
@@ -10,22 +11,47 @@ import (
 
 type resourceGroups struct {
 	Regions map[string]ResourcegroupsGroup
+	// by component, then region
+	Components map[string]map[string]ResourcegroupsGroup
 }
 
 type resourceGroupConfig struct {
-	providers providers
-	name      *string
+	providers  providers
+	name       *string
+	components []string
 }
 
 func (cfg resourceGroupConfig) new(ctx common.TfContext) resourceGroups {
+	regions := cfg.groups(ctx, *cfg.name, map[string]string{"app": *cfg.name})
+
+	components := map[string]map[string]ResourcegroupsGroup{}
+	for _, component := range cfg.components {
+		components[component] = cfg.groups(
+			common.SimpleContext(ctx.Scope, ctx.Id+"_"+component, ctx.Provider),
+			*cfg.name+"-"+component,
+			map[string]string{"app": *cfg.name, common.TAG_COMPONENT: component},
+		)
+	}
+
+	return resourceGroups{regions, components}
+}
+
+// one group per region, matching resources with every tag given
+func (cfg resourceGroupConfig) groups(ctx common.TfContext, name string, tags map[string]string) map[string]ResourcegroupsGroup {
+	keys := common.Object[string](tags).Keys()
+	sort.Strings(keys)
+
+	tagFilters := []map[string]any{}
+	for _, key := range keys {
+		tagFilters = append(tagFilters, map[string]any{
+			"Key":    key,
+			"Values": []string{tags[key]},
+		})
+	}
+
 	filter := map[string]any{
 		"ResourceTypeFilters": []string{"AWS::AllSupported"},
-		"TagFilters": []map[string]any{
-			{
-				"Key":    "app",
-				"Values": []string{*cfg.name},
-			},
-		},
+		"TagFilters":          tagFilters,
 	}
 	filterBytes, _ := json.Marshal(filter)
 
@@ -33,7 +59,7 @@ func (cfg resourceGroupConfig) new(ctx common.TfContext) resourceGroups {
 	for region, provider := range cfg.providers.All() {
 		regions[region] = NewResourcegroupsGroup(ctx.Scope, jsii.String(ctx.Id+"_"+region), &ResourcegroupsGroupConfig{
 			Provider: provider,
-			Name:     cfg.name,
+			Name:     jsii.String(name),
 			ResourceQuery: &ResourcegroupsGroupResourceQuery{
 				Type:  jsii.String("TAG_FILTERS_1_0"),
 				Query: jsii.String(string(filterBytes)),
@@ -41,5 +67,5 @@ func (cfg resourceGroupConfig) new(ctx common.TfContext) resourceGroups {
 		})
 	}
 
-	return resourceGroups{regions}
+	return regions
 }
@@ -0,0 +1,105 @@
+package bootstrap
+
+import (
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawscalleridentity"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/provider"
+)
+
+type bootstrap struct {
+	Providers common.Providers
+	State     state
+	Artifacts artifacts
+	Deployer  deployer
+}
+
+// everything the app stack expects to already exist, run once per account with admin credentials
+type BootstrapConfig struct {
+	Name    *string
+	IamPath *string
+	Tags    *map[string]*string
+	// main region first, artifacts are uploaded there and replicated to the rest
+	Regions     []string
+	StateBucket *string
+	StateKey    *string
+	StateRegion *string
+	StateTable  *string
+	// buckets are named <prefix>-<region>, see common.ObjectConfig
+	ArtifactBucketPrefix *string
+	ArtifactPrefix       *string
+	// other accounts deploying from these buckets
+	ArtifactReaders []string
+	GithubRepo      *string
+	Thumbprints     []string
+	DeployerRole    *string
+	// principals in other accounts allowed to assume the deployer role with ExternalId
+	TrustedPrincipals []string
+	ExternalId        *string
+	// optional, gets the same policies as the deployer
+	AdminGroup *string
+	// roles the deployer creates must carry this boundary, either an existing one or the one the stack creates
+	PermissionsBoundary       *string
+	CreatePermissionsBoundary bool
+	// documents with ${...} placeholders, by name
+	Policies map[string]string
+}
+
+func (cfg BootstrapConfig) New(ctx common.TfContext) bootstrap {
+	common.TagComponent(ctx, common.COMPONENT_BOOTSTRAP)
+
+	providers := cfg.providers(common.SimpleContext(ctx.Scope, ctx.Id+"_providers", nil))
+	main := providers[cfg.Regions[0]]
+
+	caller := NewDataAwsCallerIdentity(ctx.Scope, jsii.String(ctx.Id+"_caller"), &DataAwsCallerIdentityConfig{
+		Provider: main,
+	})
+
+	artifacts := artifactsConfig{
+		BootstrapConfig: cfg,
+		providers:       providers,
+	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_artifacts", main))
+
+	stateProvider, ok := providers[*cfg.StateRegion]
+	if !ok {
+		panic("state region " + *cfg.StateRegion + " isn't one of the stack's regions")
+	}
+	state := stateConfig{
+		BootstrapConfig: cfg,
+		artifacts:       artifacts,
+	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_state", stateProvider))
+
+	deployer := deployerConfig{
+		BootstrapConfig: cfg,
+		accountId:       caller.AccountId(),
+	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_deployer", main))
+
+	return bootstrap{providers, state, artifacts, deployer}
+}
+
+func (cfg BootstrapConfig) providers(ctx common.TfContext) common.Providers {
+	providers := common.Providers{}
+	for i, region := range cfg.Regions {
+		tags := map[string]*string{"region": jsii.String(region), "app": cfg.Name}
+		if cfg.Tags != nil {
+			for key, value := range *cfg.Tags {
+				tags[key] = value
+			}
+		}
+
+		// the main region's provider is the default one
+		var alias *string
+		if i > 0 {
+			alias = jsii.String(region)
+		}
+
+		providers[region] = NewAwsProvider(ctx.Scope, jsii.String(ctx.Id+"_"+region), &AwsProviderConfig{
+			Region: jsii.String(region),
+			Alias:  alias,
+			DefaultTags: &AwsProviderDefaultTags{
+				Tags: &tags,
+			},
+		})
+	}
+	return providers
+}
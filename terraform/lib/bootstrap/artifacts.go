@@ -0,0 +1,226 @@
+package bootstrap
+
+import (
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrole"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicy"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucket"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketlifecycleconfiguration"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketpolicy"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketpublicaccessblock"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketreplicationconfiguration"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketserversideencryptionconfiguration"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketversioning"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
+)
+
+type artifacts struct {
+	Buckets map[string]S3Bucket
+	// nil with a single region
+	ReplicationRole IamRole
+	names           []string
+}
+
+type artifactsConfig struct {
+	BootstrapConfig
+	providers common.Providers
+}
+
+func (cfg artifactsConfig) new(ctx common.TfContext) artifacts {
+	buckets := map[string]S3Bucket{}
+	names := []string{}
+	versioning := map[string]S3BucketVersioningA{}
+	for _, region := range cfg.Regions {
+		regionCtx := common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, cfg.providers[region])
+		names = append(names, cfg.bucketName(region))
+		buckets[region] = privateBucket(regionCtx, cfg.bucketName(region))
+
+		versioning[region] = NewS3BucketVersioningA(ctx.Scope, jsii.String(regionCtx.Id+"_versioning"), &S3BucketVersioningAConfig{
+			Provider: regionCtx.Provider,
+			Bucket:   buckets[region].Id(),
+			VersioningConfiguration: &S3BucketVersioningVersioningConfiguration{
+				Status: jsii.String("Enabled"),
+			},
+		})
+
+		// every deploy uploads new versions, old ones are only kept for rollbacks
+		NewS3BucketLifecycleConfiguration(ctx.Scope, jsii.String(regionCtx.Id+"_lifecycle"), &S3BucketLifecycleConfigurationConfig{
+			Provider: regionCtx.Provider,
+			Bucket:   buckets[region].Id(),
+			Rule: []S3BucketLifecycleConfigurationRule{
+				{
+					Id:     jsii.String("MoveOldVersionsToIA"),
+					Status: jsii.String("Enabled"),
+					Filter: &S3BucketLifecycleConfigurationRuleFilter{
+						Prefix: jsii.String(""),
+					},
+					NoncurrentVersionTransition: []S3BucketLifecycleConfigurationRuleNoncurrentVersionTransition{
+						{
+							NoncurrentDays: jsii.Number(30),
+							StorageClass:   jsii.String("STANDARD_IA"),
+						},
+					},
+				},
+			},
+		})
+
+		if len(cfg.ArtifactReaders) > 0 {
+			cfg.readerPolicy(regionCtx, buckets[region])
+		}
+	}
+
+	result := artifacts{Buckets: buckets, names: names}
+	if len(cfg.Regions) > 1 {
+		result.ReplicationRole = cfg.replication(common.SimpleContext(ctx.Scope, ctx.Id+"_replication", ctx.Provider), buckets, versioning)
+	}
+	return result
+}
+
+func (cfg artifactsConfig) bucketName(region string) string {
+	return *common.ObjectConfig{Bucket: cfg.ArtifactBucketPrefix}.ToBucket(region)
+}
+
+// lambda fetches code as whoever calls CreateFunction, so other accounts' deploy roles need to read it
+func (cfg artifactsConfig) readerPolicy(ctx common.TfContext, bucket S3Bucket) {
+	NewS3BucketPolicy(ctx.Scope, jsii.String(ctx.Id+"_policy"), &S3BucketPolicyConfig{
+		Provider: ctx.Provider,
+		Bucket:   bucket.Id(),
+		Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Sid:       jsii.String("AllowReaders"),
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("s3:GetObject*", "s3:ListBucket"),
+					Resources: jsii.Strings(*bucket.Arn(), *bucket.Arn()+"/*"),
+					Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+						{
+							Type:        jsii.String("AWS"),
+							Identifiers: jsii.Strings(cfg.ArtifactReaders...),
+						},
+					},
+				},
+			},
+		}).Json(),
+	})
+}
+
+// uploads go to the main region only, new versions keep their version id in every copy
+func (cfg artifactsConfig) replication(ctx common.TfContext, buckets map[string]S3Bucket, versioning map[string]S3BucketVersioningA) IamRole {
+	source := buckets[cfg.Regions[0]]
+
+	destinationArns := []*string{}
+	for _, region := range cfg.Regions[1:] {
+		destinationArns = append(destinationArns, jsii.String(*buckets[region].Arn()+"/*"))
+	}
+
+	role := NewIamRole(ctx.Scope, jsii.String(ctx.Id+"_role"), &IamRoleConfig{
+		Provider: ctx.Provider,
+		Name:     jsii.String(*cfg.Name + "-artifact-replication"),
+		Path:     cfg.IamPath,
+		AssumeRolePolicy: common.ServiceAssumeRoleConfig{
+			Service: "s3.amazonaws.com",
+		}.Doc(common.SimpleContext(ctx.Scope, ctx.Id+"_assume_role", ctx.Provider)).Json(),
+	})
+
+	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_role_policy"), &IamRolePolicyConfig{
+		Provider: ctx.Provider,
+		Name:     jsii.String("replication"),
+		Role:     role.Name(),
+		Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_role_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("s3:GetReplicationConfiguration", "s3:ListBucket"),
+					Resources: jsii.Strings(*source.Arn()),
+				},
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("s3:GetObjectVersionAcl", "s3:GetObjectVersionForReplication", "s3:GetObjectVersionTagging"),
+					Resources: jsii.Strings(*source.Arn() + "/*"),
+				},
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("s3:ReplicateDelete", "s3:ReplicateObject", "s3:ReplicateTags"),
+					Resources: &destinationArns,
+				},
+			},
+		}).Json(),
+	})
+
+	// the state file can share the main bucket, only artifacts are copied
+	rules := []S3BucketReplicationConfigurationRule{}
+	dependsOn := []cdktf.ITerraformDependable{}
+	for i, region := range cfg.Regions {
+		dependsOn = append(dependsOn, versioning[region])
+		if i == 0 {
+			continue
+		}
+		rules = append(rules, S3BucketReplicationConfigurationRule{
+			Id:       jsii.String(region),
+			Priority: jsii.Number(float64(i)),
+			Status:   jsii.String("Enabled"),
+			Filter: &S3BucketReplicationConfigurationRuleFilter{
+				Prefix: cfg.ArtifactPrefix,
+			},
+			DeleteMarkerReplication: &S3BucketReplicationConfigurationRuleDeleteMarkerReplication{
+				Status: jsii.String("Enabled"),
+			},
+			Destination: &S3BucketReplicationConfigurationRuleDestination{
+				Bucket: buckets[region].Arn(),
+			},
+		})
+	}
+
+	NewS3BucketReplicationConfigurationA(ctx.Scope, jsii.String(ctx.Id), &S3BucketReplicationConfigurationAConfig{
+		Provider:  ctx.Provider,
+		Bucket:    source.Id(),
+		Role:      role.Arn(),
+		Rule:      rules,
+		DependsOn: &dependsOn,
+	})
+
+	return role
+}
+
+func (app artifacts) has(bucket string) bool {
+	for _, name := range app.names {
+		if name == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// no public access, s3 managed encryption so replication doesn't need key grants
+func privateBucket(ctx common.TfContext, name string) S3Bucket {
+	bucket := NewS3Bucket(ctx.Scope, jsii.String(ctx.Id+"_bucket"), &S3BucketConfig{
+		Provider:  ctx.Provider,
+		Bucket:    jsii.String(name),
+		Lifecycle: common.Protect(true),
+	})
+
+	NewS3BucketPublicAccessBlock(ctx.Scope, jsii.String(ctx.Id+"_public_access"), &S3BucketPublicAccessBlockConfig{
+		Provider:              ctx.Provider,
+		Bucket:                bucket.Id(),
+		BlockPublicAcls:       jsii.Bool(true),
+		BlockPublicPolicy:     jsii.Bool(true),
+		IgnorePublicAcls:      jsii.Bool(true),
+		RestrictPublicBuckets: jsii.Bool(true),
+	})
+
+	NewS3BucketServerSideEncryptionConfigurationA(ctx.Scope, jsii.String(ctx.Id+"_encryption"), &S3BucketServerSideEncryptionConfigurationAConfig{
+		Provider: ctx.Provider,
+		Bucket:   bucket.Id(),
+		Rule: []S3BucketServerSideEncryptionConfigurationRuleA{
+			{
+				ApplyServerSideEncryptionByDefault: &S3BucketServerSideEncryptionConfigurationRuleApplyServerSideEncryptionByDefaultA{
+					SseAlgorithm: jsii.String("AES256"),
+				},
+			},
+		},
+	})
+
+	return bucket
+}
@@ -0,0 +1,178 @@
+package bootstrap
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamgroup"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamgrouppolicyattachment"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamopenidconnectprovider"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iampolicy"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrole"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicyattachment"
+)
+
+type deployer struct {
+	Oidc     IamOpenidConnectProvider
+	Role     IamRole
+	Policies map[string]IamPolicy
+	// nil unless an admin group was asked for
+	AdminGroup IamGroup
+}
+
+type deployerConfig struct {
+	BootstrapConfig
+	accountId *string
+}
+
+var placeholder = regexp.MustCompile(`\$\{[A-Za-z]+\}`)
+
+func (cfg deployerConfig) new(ctx common.TfContext) deployer {
+	oidc := NewIamOpenidConnectProvider(ctx.Scope, jsii.String(ctx.Id+"_github_oidc"), &IamOpenidConnectProviderConfig{
+		Provider:       ctx.Provider,
+		Url:            jsii.String("https://token.actions.githubusercontent.com"),
+		ClientIdList:   jsii.Strings("sts.amazonaws.com"),
+		ThumbprintList: jsii.Strings(cfg.Thumbprints...),
+	})
+
+	role := NewIamRole(ctx.Scope, jsii.String(ctx.Id+"_role"), &IamRoleConfig{
+		Provider:         ctx.Provider,
+		Name:             cfg.DeployerRole,
+		Path:             cfg.IamPath,
+		AssumeRolePolicy: cfg.assumeRole(common.SimpleContext(ctx.Scope, ctx.Id+"_assume_role", ctx.Provider), oidc).Json(),
+	})
+
+	var group IamGroup
+	if cfg.AdminGroup != nil {
+		group = NewIamGroup(ctx.Scope, jsii.String(ctx.Id+"_admin_group"), &IamGroupConfig{
+			Provider: ctx.Provider,
+			Name:     cfg.AdminGroup,
+			Path:     cfg.IamPath,
+		})
+	}
+
+	// deterministic ids, one managed policy per file to stay under the size limit
+	names := common.Object[string](cfg.Policies).Keys()
+	sort.Strings(names)
+
+	policies := map[string]IamPolicy{}
+	for _, name := range names {
+		policies[name] = NewIamPolicy(ctx.Scope, jsii.String(ctx.Id+"_policy_"+name), &IamPolicyConfig{
+			Provider:    ctx.Provider,
+			Name:        jsii.String(*cfg.Name + "-" + name),
+			Path:        cfg.IamPath,
+			Description: jsii.String("Deploys the " + *cfg.Name + " stacks"),
+			Policy:      jsii.String(cfg.render(name)),
+		})
+
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_role_"+name), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      role.Name(),
+			PolicyArn: policies[name].Arn(),
+		})
+
+		if group != nil {
+			NewIamGroupPolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_admin_group_"+name), &IamGroupPolicyAttachmentConfig{
+				Provider:  ctx.Provider,
+				Group:     group.Name(),
+				PolicyArn: policies[name].Arn(),
+			})
+		}
+	}
+
+	return deployer{oidc, role, policies, group}
+}
+
+// github actions on the repo, plus principals in other accounts that deploy into this one
+func (cfg deployerConfig) assumeRole(ctx common.TfContext, oidc IamOpenidConnectProvider) DataAwsIamPolicyDocument {
+	statements := []DataAwsIamPolicyDocumentStatement{
+		{
+			Sid:     jsii.String("AllowGithubActions"),
+			Effect:  jsii.String("Allow"),
+			Actions: jsii.Strings("sts:AssumeRoleWithWebIdentity"),
+			Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+				{
+					Type:        jsii.String("Federated"),
+					Identifiers: jsii.Strings(*oidc.Arn()),
+				},
+			},
+			Condition: []DataAwsIamPolicyDocumentStatementCondition{
+				{
+					Test:     jsii.String("StringEquals"),
+					Variable: jsii.String("token.actions.githubusercontent.com:aud"),
+					Values:   jsii.Strings("sts.amazonaws.com"),
+				},
+				{
+					Test:     jsii.String("StringLike"),
+					Variable: jsii.String("token.actions.githubusercontent.com:sub"),
+					Values:   jsii.Strings("repo:" + *cfg.GithubRepo + ":*"),
+				},
+			},
+		},
+	}
+
+	if len(cfg.TrustedPrincipals) > 0 {
+		if cfg.ExternalId == nil {
+			panic("trusted principals need an external id")
+		}
+		statements = append(statements, DataAwsIamPolicyDocumentStatement{
+			Sid:     jsii.String("AllowCrossAccountDeployers"),
+			Effect:  jsii.String("Allow"),
+			Actions: jsii.Strings("sts:AssumeRole"),
+			Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+				{
+					Type:        jsii.String("AWS"),
+					Identifiers: jsii.Strings(cfg.TrustedPrincipals...),
+				},
+			},
+			Condition: []DataAwsIamPolicyDocumentStatementCondition{
+				{
+					Test:     jsii.String("StringEquals"),
+					Variable: jsii.String("sts:ExternalId"),
+					Values:   jsii.Strings(*cfg.ExternalId),
+				},
+			},
+		})
+	}
+
+	return NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id), &DataAwsIamPolicyDocumentConfig{
+		Statement: statements,
+	})
+}
+
+// see base's policies, the stack names its boundary <name>-boundary under the iam path
+func (cfg deployerConfig) boundary() *string {
+	if cfg.PermissionsBoundary != nil {
+		return cfg.PermissionsBoundary
+	} else if !cfg.CreatePermissionsBoundary {
+		panic("the deployer can only create roles with a permissions boundary")
+	}
+	return jsii.String("arn:aws:iam::" + *cfg.accountId + ":policy" + *cfg.IamPath + *cfg.Name + "-boundary")
+}
+
+// fills in the policy's placeholders, anything unknown is a typo in the file
+func (cfg deployerConfig) render(name string) string {
+	policy := strings.NewReplacer(
+		"${Prefix}", *cfg.Name,
+		"${IamPath}", *cfg.IamPath,
+		"${StateBucket}", *cfg.StateBucket,
+		"${StateKey}", *cfg.StateKey,
+		"${StateTable}", *cfg.StateTable,
+		"${ArtifactBucketPrefix}", *cfg.ArtifactBucketPrefix,
+		"${ArtifactPrefix}", *cfg.ArtifactPrefix,
+		"${DeployerRole}", *cfg.DeployerRole,
+		"${PermissionsBoundary}", *cfg.boundary(),
+	).Replace(cfg.Policies[name])
+
+	// the account id is a token, so it goes in last
+	for _, unknown := range placeholder.FindAllString(policy, -1) {
+		if unknown != "${AccountId}" {
+			panic("unknown placeholder " + unknown + " in policy " + name)
+		}
+	}
+	return strings.ReplaceAll(policy, "${AccountId}", *cfg.accountId)
+}
@@ -0,0 +1,56 @@
+package bootstrap
+
+import (
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dynamodbtable"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucket"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketversioning"
+)
+
+type state struct {
+	// nil when the state lives in one of the artifact buckets
+	Bucket S3Bucket
+	Table  DynamodbTable
+}
+
+type stateConfig struct {
+	BootstrapConfig
+	artifacts artifacts
+}
+
+func (cfg stateConfig) new(ctx common.TfContext) state {
+	// existing stacks keep their state next to the main region's artifacts
+	var bucket S3Bucket
+	if !cfg.artifacts.has(*cfg.StateBucket) {
+		bucket = privateBucket(ctx, *cfg.StateBucket)
+
+		NewS3BucketVersioningA(ctx.Scope, jsii.String(ctx.Id+"_versioning"), &S3BucketVersioningAConfig{
+			Provider: ctx.Provider,
+			Bucket:   bucket.Id(),
+			VersioningConfiguration: &S3BucketVersioningVersioningConfiguration{
+				Status: jsii.String("Enabled"),
+			},
+		})
+	}
+
+	table := NewDynamodbTable(ctx.Scope, jsii.String(ctx.Id+"_lock_table"), &DynamodbTableConfig{
+		Provider:    ctx.Provider,
+		Name:        cfg.StateTable,
+		BillingMode: jsii.String("PAY_PER_REQUEST"),
+		TableClass:  jsii.String("STANDARD"),
+		HashKey:     jsii.String("LockID"),
+		Lifecycle:   common.Protect(true),
+		ServerSideEncryption: &DynamodbTableServerSideEncryption{
+			Enabled: jsii.Bool(true),
+		},
+		Attribute: &[]DynamodbTableAttribute{
+			{
+				Name: jsii.String("LockID"),
+				Type: jsii.String("S"),
+			},
+		},
+	})
+
+	return state{bucket, table}
+}
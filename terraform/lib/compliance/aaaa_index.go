@@ -0,0 +1,61 @@
+package compliance
+
+import (
+	"fmt"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
+)
+
+type Level string
+
+const (
+	// fails synthesis
+	LEVEL_ERROR Level = "error"
+	LEVEL_WARN  Level = "warn"
+	LEVEL_OFF   Level = "off"
+)
+
+type ComplianceConfig struct {
+	Name        *string
+	Encryption  Level
+	Retention   Level
+	Wildcards   Level
+	DeadLetters Level
+	Tags        Level
+	// tables allowed to skip encryption, relative to Name
+	UnencryptedTables []string
+	// keys every taggable resource needs, either directly or through its provider's default tags
+	RequiredTags []string
+}
+
+// walks everything under the scope at synth time, anything created after this is still checked
+func (cfg ComplianceConfig) New(ctx common.TfContext) {
+	unencrypted := map[string]bool{}
+	for _, table := range cfg.UnencryptedTables {
+		unencrypted[*cfg.Name+"-"+table] = true
+	}
+
+	aspects := cdktf.Aspects_Of(ctx.Scope)
+	aspects.Add(&encryptionAspect{level: cfg.Encryption, allowed: unencrypted})
+	aspects.Add(&retentionAspect{level: cfg.Retention})
+	aspects.Add(&wildcardAspect{level: cfg.Wildcards})
+	aspects.Add(&deadLetterAspect{level: cfg.DeadLetters})
+	aspects.Add(&tagAspect{level: cfg.Tags, required: cfg.RequiredTags})
+}
+
+func (level Level) report(node constructs.IConstruct, check string, message string) {
+	if level == LEVEL_OFF || common.IsExempt(node, check) {
+		return
+	}
+
+	annotation := jsii.String(fmt.Sprintf("[compliance/%s] %s: %s", check, *node.Node().Path(), message))
+	if level == LEVEL_WARN {
+		cdktf.Annotations_Of(node).AddWarning(annotation)
+	} else {
+		// anything unrecognised is treated as an error
+		cdktf.Annotations_Of(node).AddError(annotation)
+	}
+}
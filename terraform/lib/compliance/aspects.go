@@ -0,0 +1,196 @@
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/constructs-go/constructs/v10"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchloggroup"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dynamodbtable"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/lambdaeventsourcemapping"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/lambdafunction"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/lambdafunctioneventinvokeconfig"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/provider"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
+)
+
+type encryptionAspect struct {
+	level   Level
+	allowed map[string]bool
+}
+
+func (aspect *encryptionAspect) Visit(node constructs.IConstruct) {
+	table, ok := node.(DynamodbTable)
+	if !ok || table.NameInput() != nil && aspect.allowed[*table.NameInput()] {
+		return
+	}
+
+	sse := table.ServerSideEncryptionInput()
+	if sse == nil {
+		aspect.level.report(node, common.CHECK_ENCRYPTION, "table has no server side encryption")
+	} else if enabled, known := boolInput(sse.Enabled); known && !enabled {
+		aspect.level.report(node, common.CHECK_ENCRYPTION, "table has server side encryption disabled")
+	}
+}
+
+// bool inputs are bool, *bool or a token depending on how they were set, tokens can't be judged at synth time
+func boolInput(value interface{}) (result bool, known bool) {
+	switch value := value.(type) {
+	case bool:
+		return value, true
+	case *bool:
+		return value != nil && *value, true
+	case nil:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+type retentionAspect struct {
+	level Level
+}
+
+func (aspect *retentionAspect) Visit(node constructs.IConstruct) {
+	logGroup, ok := node.(CloudwatchLogGroup)
+	if !ok {
+		return
+	}
+
+	// 0 is never expire
+	if retention := logGroup.RetentionInDaysInput(); retention == nil || *retention == 0 {
+		aspect.level.report(node, common.CHECK_RETENTION, "log group never expires its logs")
+	}
+}
+
+type wildcardAspect struct {
+	level Level
+}
+
+// only policy documents are inspected, raw json policies are opaque at synth time
+func (aspect *wildcardAspect) Visit(node constructs.IConstruct) {
+	doc, ok := node.(DataAwsIamPolicyDocument)
+	if !ok || doc.StatementInput() == nil {
+		return
+	}
+
+	// statements come back from jsii untyped, round trip them into something we can read
+	statements := []DataAwsIamPolicyDocumentStatement{}
+	if raw, err := json.Marshal(doc.StatementInput()); err != nil || json.Unmarshal(raw, &statements) != nil {
+		aspect.level.report(node, common.CHECK_WILDCARDS, "could not read policy statements")
+		return
+	}
+
+	for i, statement := range statements {
+		// denies can be as broad as they like, and "*" in a resource policy just means the resource itself
+		if statement.Effect != nil && *statement.Effect == "Deny" || statement.Principals != nil {
+			continue
+		}
+
+		name := fmt.Sprintf("statement %d", i)
+		if statement.Sid != nil {
+			name = "statement " + *statement.Sid
+		}
+		if statement.NotActions != nil {
+			aspect.level.report(node, common.CHECK_WILDCARDS, name+" allows everything but a list of actions")
+		}
+
+		partialWildcard := false
+		for _, action := range listOrEmpty(statement.Actions) {
+			if *action == "*" || strings.HasSuffix(*action, ":*") {
+				aspect.level.report(node, common.CHECK_WILDCARDS, name+" allows every action in "+*action)
+			} else if strings.Contains(*action, "*") {
+				partialWildcard = true
+			}
+		}
+
+		for _, resource := range listOrEmpty(statement.Resources) {
+			if *resource != "*" {
+				continue
+			}
+
+			// some actions can't be scoped to a resource, a condition is the only way to narrow them
+			if partialWildcard {
+				aspect.level.report(node, common.CHECK_WILDCARDS, name+" combines wildcard actions with every resource")
+			} else if statement.Condition == nil {
+				aspect.level.report(node, common.CHECK_WILDCARDS, name+" applies to every resource without a condition")
+			}
+		}
+	}
+}
+
+type deadLetterAspect struct {
+	level Level
+}
+
+// lambdas that are only ever invoked synchronously, or whose failures are handled elsewhere, are exempted where they're created
+func (aspect *deadLetterAspect) Visit(node constructs.IConstruct) {
+	if lambda, ok := node.(LambdaFunction); ok {
+		if dlq := lambda.DeadLetterConfigInput(); dlq == nil || dlq.TargetArn == nil {
+			aspect.level.report(node, common.CHECK_DEAD_LETTERS, "function has no dead letter queue")
+		}
+	} else if mapping, ok := node.(LambdaEventSourceMapping); ok {
+		if destination := mapping.DestinationConfigInput(); destination == nil || destination.OnFailure == nil {
+			aspect.level.report(node, common.CHECK_DEAD_LETTERS, "event source mapping has no failure destination")
+		}
+	} else if invokeConfig, ok := node.(LambdaFunctionEventInvokeConfig); ok {
+		if destination := invokeConfig.DestinationConfigInput(); destination == nil || destination.OnFailure == nil {
+			aspect.level.report(node, common.CHECK_DEAD_LETTERS, "event invoke config has no failure destination")
+		}
+	}
+}
+
+type tagAspect struct {
+	level    Level
+	required []string
+}
+
+type taggable interface {
+	TagsInput() *map[string]*string
+}
+
+func (aspect *tagAspect) Visit(node constructs.IConstruct) {
+	resource, ok := node.(cdktf.TerraformResource)
+	if !ok {
+		return
+	}
+	tagged, ok := node.(taggable)
+	if !ok {
+		return
+	}
+
+	tags := map[string]*string{}
+	if provider, ok := resource.Provider().(AwsProvider); ok && provider.DefaultTagsInput() != nil && provider.DefaultTagsInput().Tags != nil {
+		for key, value := range *provider.DefaultTagsInput().Tags {
+			tags[key] = value
+		}
+	}
+	if tagged.TagsInput() != nil {
+		for key, value := range *tagged.TagsInput() {
+			tags[key] = value
+		}
+	}
+
+	missing := []string{}
+	for _, key := range aspect.required {
+		if _, ok := tags[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(tags) == 0 {
+		aspect.level.report(node, common.CHECK_TAGS, "resource is untagged")
+	} else if len(missing) > 0 {
+		aspect.level.report(node, common.CHECK_TAGS, "resource is missing tags: "+strings.Join(missing, ", "))
+	}
+}
+
+func listOrEmpty(list *[]*string) []*string {
+	if list == nil {
+		return []*string{}
+	}
+	return *list
+}
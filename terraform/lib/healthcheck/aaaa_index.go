@@ -10,6 +10,12 @@ type healthcheck struct {
 	Responder     healthcheckResponder
 	cron          healthcheckCron
 	Alarm         healthcheckAlarm
+	// zero value when the canary is disabled
+	Canary healthcheckCanary
+	// nil regions when probing is disabled or there's only one region
+	ProbeAlarm healthcheckProbeAlarm
+	// enforced by the api when healthchecks are posted
+	Ttl *float64
 }
 
 type HealthcheckConfig struct {
@@ -20,11 +26,35 @@ type HealthcheckConfig struct {
 	KmsArns        common.MultiRegionId
 	Code           common.ObjectConfig
 	LambdaIam      common.LambdaIamConfig
-	ApiUrl         string
-	SendAlarmsTo   []string
+	Tracing        common.LambdaTracingConfig
+	Logs           common.LogGroupConfig
+	// topics are filled in with the healthcheck alarm topics
+	IteratorAge  common.IteratorAgeAlarmConfig
+	AccountId    *string
+	ApiUrl       string
+	SendAlarmsTo []string
+	// eventbridge schedule expression
+	Schedule *string
+	Policy   HealthcheckAlarmPolicy
+	Ttl      *float64
+	// encrypt alarm topics with the main key
+	EncryptTopics   bool
+	MetricNamespace *string
+	Canary          HealthcheckCanaryConfig
+	Probe           HealthcheckProbeConfig
+}
+
+type HealthcheckAlarmPolicy struct {
+	Threshold         *float64
+	Period            *float64
+	EvaluationPeriods *float64
+	DatapointsToAlarm *float64
+	TreatMissingData  *string
 }
 
 func (cfg HealthcheckConfig) New(ctx common.TfContext) healthcheck {
+	common.TagComponent(ctx, common.COMPONENT_HEALTHCHECK)
+
 	healthchecker := healthcheckerConfig{
 		providers:      cfg.Providers,
 		name:           cfg.Name,
@@ -32,15 +62,37 @@ func (cfg HealthcheckConfig) New(ctx common.TfContext) healthcheck {
 		kmsWritePolicy: cfg.KmsWritePolicy,
 		code:           cfg.Code,
 		lambdaIam:      cfg.LambdaIam,
+		tracing:        cfg.Tracing,
+		logs:           cfg.Logs,
 		apiUrl:         cfg.ApiUrl,
+		namespace:      cfg.MetricNamespace,
+		probe:          cfg.Probe.Enabled && len(cfg.Providers) > 1,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id, ctx.Provider))
 
+	healthcheckAlarm := healthcheckAlarmConfig{
+		providers:     cfg.Providers,
+		name:          jsii.String(*cfg.Name + "-alarm"),
+		healthchecker: healthchecker,
+		accountId:     cfg.AccountId,
+		sendAlarmsTo:  cfg.SendAlarmsTo,
+		policy:        cfg.Policy,
+		kmsArns:       cfg.KmsArns,
+		encrypt:       cfg.EncryptTopics,
+	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_alarm", ctx.Provider))
+
+	// stream alarms go to the same topic as the healthcheck alarm
+	iteratorAge := cfg.IteratorAge
+	iteratorAge.Topics = healthcheckAlarm.TopicArns()
+
 	healthcheckResponder := healthcheckResponderConfig{
 		providers:     cfg.Providers,
 		name:          jsii.String(*cfg.Name + "-responder"),
 		kmsReadPolicy: cfg.KmsReadPolicy,
 		code:          cfg.Code,
 		lambdaIam:     cfg.LambdaIam,
+		tracing:       cfg.Tracing,
+		logs:          cfg.Logs,
+		iteratorAge:   iteratorAge,
 		apiUrl:        cfg.ApiUrl,
 		healthchecker: healthchecker,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_responder", ctx.Provider))
@@ -48,20 +100,42 @@ func (cfg HealthcheckConfig) New(ctx common.TfContext) healthcheck {
 	healthcheckCron := healthcheckCronConfig{
 		providers:     cfg.Providers,
 		name:          jsii.String(*cfg.Name + "-cron"),
+		schedule:      cfg.Schedule,
 		healthchecker: healthchecker,
 	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_cron", ctx.Provider))
 
-	healthcheckAlarm := healthcheckAlarmConfig{
-		providers:     cfg.Providers,
-		name:          jsii.String(*cfg.Name + "-alarm"),
-		healthchecker: healthchecker,
-		sendAlarmsTo:  cfg.SendAlarmsTo,
-	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_alarm", ctx.Provider))
-
-	return healthcheck{
+	result := healthcheck{
 		Healthchecker: healthchecker,
 		Responder:     healthcheckResponder,
 		cron:          healthcheckCron,
 		Alarm:         healthcheckAlarm,
+		Ttl:           cfg.Ttl,
 	}
+
+	if healthchecker.probe {
+		result.ProbeAlarm = healthcheckProbeAlarmConfig{
+			HealthcheckProbeConfig: cfg.Probe,
+			providers:              cfg.Providers,
+			name:                   cfg.Name,
+			namespace:              cfg.MetricNamespace,
+			topics:                 healthcheckAlarm.TopicArns(),
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_probe_alarm", ctx.Provider))
+	}
+
+	if cfg.Canary.Enabled {
+		result.Canary = healthcheckCanaryConfig{
+			HealthcheckCanaryConfig: cfg.Canary,
+			providers:               cfg.Providers,
+			name:                    jsii.String(*cfg.Name + "-canary"),
+			code:                    cfg.Code,
+			lambdaIam:               cfg.LambdaIam,
+			tracing:                 cfg.Tracing,
+			logs:                    cfg.Logs,
+			namespace:               cfg.MetricNamespace,
+			apiUrl:                  cfg.ApiUrl,
+			topics:                  healthcheckAlarm.TopicArns(),
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_canary", ctx.Provider))
+	}
+
+	return result
 }
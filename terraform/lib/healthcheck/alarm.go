@@ -25,9 +25,11 @@ type healthcheckAlarmConfig struct {
 	providers     common.Providers
 	name          *string
 	healthchecker healthchecker
+	accountId     *string
 	sendAlarmsTo  []string
-	// may need "kmsIds"
-	// kmsArns       common.MultiRegionId
+	policy        HealthcheckAlarmPolicy
+	kmsArns       common.MultiRegionId
+	encrypt       bool
 }
 
 type healthcheckAlarmInstanceConfig struct {
@@ -49,12 +51,16 @@ func (cfg healthcheckAlarmConfig) new(ctx common.TfContext) healthcheckAlarm {
 }
 
 func (cfg healthcheckAlarmInstanceConfig) new(ctx common.TfContext) healthcheckAlarmInstance {
-	topic := NewSnsTopic(ctx.Scope, jsii.String(ctx.Id+"_topic"), &SnsTopicConfig{
+	topicConfig := &SnsTopicConfig{
 		Provider: ctx.Provider,
 		Name:     cfg.name,
-		// skipping encryption out of sheer laziness for now
-		// KmsMasterKeyId: cfg.healthchecker.Regions[cfg.region].Table.ServerSideEncryptionInput().KmsKeyArn(),
-	})
+	}
+
+	if cfg.encrypt {
+		topicConfig.KmsMasterKeyId = cfg.kmsArns.Region(cfg.region)
+	}
+
+	topic := NewSnsTopic(ctx.Scope, jsii.String(ctx.Id+"_topic"), topicConfig)
 
 	for _, address := range cfg.sendAlarmsTo {
 		NewSnsTopicSubscription(ctx.Scope, jsii.String(ctx.Id+"_topic_sub_"+address), &SnsTopicSubscriptionConfig{
@@ -73,11 +79,11 @@ func (cfg healthcheckAlarmInstanceConfig) new(ctx common.TfContext) healthcheckA
 		MetricName:         jsii.String("Errors"),
 		Statistic:          jsii.String("Sum"),
 		ComparisonOperator: jsii.String("GreaterThanOrEqualToThreshold"),
-		Threshold:          jsii.Number(1),
-		EvaluationPeriods:  jsii.Number(5),
-		Period:             jsii.Number(60),
-		DatapointsToAlarm:  jsii.Number(3),
-		TreatMissingData:   jsii.String("breaching"),
+		Threshold:          cfg.policy.Threshold,
+		EvaluationPeriods:  cfg.policy.EvaluationPeriods,
+		Period:             cfg.policy.Period,
+		DatapointsToAlarm:  cfg.policy.DatapointsToAlarm,
+		TreatMissingData:   cfg.policy.TreatMissingData,
 		AlarmActions:       jsii.Strings(*topic.Arn()),
 		OkActions:          jsii.Strings(*topic.Arn()),
 		Dimensions: &map[string]*string{
@@ -101,10 +107,11 @@ func (cfg healthcheckAlarmInstanceConfig) new(ctx common.TfContext) healthcheckA
 						},
 					},
 					Condition: []DataAwsIamPolicyDocumentStatementCondition{
+						// other alarms in the region (e.g. stream iterator age) publish here too
 						{
-							Test:     jsii.String("ArnEquals"),
+							Test:     jsii.String("ArnLike"),
 							Variable: jsii.String("aws:SourceArn"),
-							Values:   jsii.Strings(*alarm.Arn()),
+							Values:   jsii.Strings(fmt.Sprintf("arn:aws:cloudwatch:%s:%s:alarm:*", cfg.region, *cfg.accountId)),
 						},
 					},
 				},
@@ -120,3 +127,11 @@ func (app healthcheckAlarm) AlarmIds() map[string]common.ArnIdPair {
 		return common.ArnIdPair{Arn: instance.Alarm.Arn(), Id: instance.Alarm.AlarmName()}
 	})
 }
+
+func (app healthcheckAlarm) TopicArns() map[string]*string {
+	result := map[string]*string{}
+	for region, instance := range app.Regions {
+		result[region] = instance.Topic.Arn()
+	}
+	return result
+}
@@ -0,0 +1,283 @@
+package healthcheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatcheventrule"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatcheventtarget"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawss3object"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrole"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicy"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicyattachment"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/lambdafunction"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/lambdapermission"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
+)
+
+// synthetic users per region, the healthcheck queue pairs them up
+const canaryUsers = 2
+
+type healthcheckCanary struct {
+	Regions    map[string]healthcheckCanaryInstance
+	LambdaRole IamRole
+}
+
+type healthcheckCanaryInstance struct {
+	Function LambdaFunction
+	Rule     CloudwatchEventRule
+	Alarm    CloudwatchMetricAlarm
+}
+
+type HealthcheckCanaryConfig struct {
+	Enabled bool
+	// eventbridge schedule expression
+	Schedule *string
+	// seconds the synthetic users have to get matched
+	Deadline          *float64
+	Period            *float64
+	EvaluationPeriods *float64
+	DatapointsToAlarm *float64
+}
+
+type healthcheckCanaryConfig struct {
+	HealthcheckCanaryConfig
+	providers common.Providers
+	name      *string
+	code      common.ObjectConfig
+	lambdaIam common.LambdaIamConfig
+	tracing   common.LambdaTracingConfig
+	logs      common.LogGroupConfig
+	namespace *string
+	apiUrl    string
+	// alarm/ok notifications, keyed by region
+	topics map[string]*string
+}
+
+type healthcheckCanaryInstanceConfig struct {
+	healthcheckCanaryConfig
+	region string
+	role   *string
+}
+
+func (cfg healthcheckCanaryConfig) new(ctx common.TfContext) healthcheckCanary {
+	// create lambda role
+	lambdaRole := cfg.lambdaRole(common.SimpleContext(ctx.Scope, ctx.Id+"_lambda_role", ctx.Provider))
+
+	// create an instance of the service in each region
+	instances := map[string]healthcheckCanaryInstance{}
+	for region, provider := range cfg.providers {
+		instances[region] = healthcheckCanaryInstanceConfig{
+			healthcheckCanaryConfig: cfg,
+			region:                  region,
+			role:                    lambdaRole.Arn(),
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider))
+	}
+
+	return healthcheckCanary{instances, lambdaRole}
+}
+
+func (app healthcheckCanary) AddApiPerms(ctx common.TfContext, arns []*string) {
+	// canary is disabled
+	if app.LambdaRole == nil {
+		return
+	}
+
+	resources := []*string{}
+	for _, arn := range arns {
+		resources = append(resources, []*string{
+			jsii.String(*arn + "/types/Subscription/fields/joinHealthcheckQueue"),
+			jsii.String(*arn + "/types/Match/fields/*"),
+			jsii.String(*arn + "/types/Heartbeat/fields/*"),
+		}...)
+	}
+
+	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_lambda_role_appsync_policy"), &IamRolePolicyConfig{
+		Provider: ctx.Provider,
+		Name:     jsii.String("appsync"),
+		Role:     app.LambdaRole.Name(),
+		Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_lambda_role_appsync_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("appsync:GraphQL"),
+					Resources: &resources,
+				},
+			},
+		}).Json(),
+	})
+}
+
+func (cfg healthcheckCanaryConfig) lambdaRole(ctx common.TfContext) IamRole {
+	lambdaRole := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.name + "-lambda"),
+		Path:                cfg.lambdaIam.Path,
+		AssumeRolePolicy:    cfg.lambdaIam.AssumeRole,
+		PermissionsBoundary: cfg.lambdaIam.PermissionsBoundary,
+	})
+
+	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_policy_exec"), &IamRolePolicyAttachmentConfig{
+		Provider:  ctx.Provider,
+		Role:      lambdaRole.Name(),
+		PolicyArn: cfg.lambdaIam.ExecPolicy,
+	})
+
+	if cfg.tracing.Enabled {
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_xray"), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      lambdaRole.Name(),
+			PolicyArn: cfg.tracing.Policy,
+		})
+	}
+
+	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_custom_policy"), &IamRolePolicyConfig{
+		Provider: ctx.Provider,
+		Name:     jsii.String("canary"),
+		Role:     lambdaRole.Name(),
+		Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_custom_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("cloudwatch:PutMetricData"),
+					Resources: jsii.Strings("*"),
+					Condition: []DataAwsIamPolicyDocumentStatementCondition{
+						{
+							Test:     jsii.String("StringEquals"),
+							Variable: jsii.String("cloudwatch:namespace"),
+							Values:   jsii.Strings(*cfg.namespace),
+						},
+					},
+				},
+			},
+		}).Json(),
+	})
+
+	return lambdaRole
+}
+
+func (cfg healthcheckCanaryInstanceConfig) new(ctx common.TfContext) healthcheckCanaryInstance {
+	logGroup := cfg.logs.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_logs", ctx.Provider),
+		jsii.String("/aws/lambda/"+*cfg.name),
+		cfg.region,
+	)
+
+	code := NewDataAwsS3Object(ctx.Scope, jsii.String(ctx.Id+"_code"), &DataAwsS3ObjectConfig{
+		Provider: ctx.Provider,
+		Bucket:   cfg.code.ToBucket(cfg.region),
+		Key:      cfg.code.ToKey("rust/target/lambda/canary/bootstrap.zip"),
+	})
+
+	// dedicated users per region, re-queueing them just overwrites any leftovers from a failed run
+	users := []string{}
+	for i := 1; i <= canaryUsers; i++ {
+		users = append(users, fmt.Sprintf("%s-%s-%d", *cfg.name, cfg.region, i))
+	}
+
+	lambdaEnv := map[string]*string{
+		"API_URL":          jsii.String(strings.Replace(cfg.apiUrl, "<region>", cfg.region, -1)),
+		"METRIC_NAMESPACE": cfg.namespace,
+		"USER_IDS":         jsii.String(strings.Join(users, ",")),
+		"DEADLINE_SECONDS": jsii.String(fmt.Sprint(*cfg.Deadline)),
+	}
+
+	lambdaDependsOn := []cdktf.ITerraformDependable{
+		logGroup,
+	}
+
+	lambda := NewLambdaFunction(ctx.Scope, jsii.String(ctx.Id+"_lambda"), &LambdaFunctionConfig{
+		Provider:        ctx.Provider,
+		FunctionName:    cfg.name,
+		Role:            cfg.role,
+		S3Bucket:        code.Bucket(),
+		S3Key:           code.Key(),
+		S3ObjectVersion: code.VersionId(),
+		Architectures:   jsii.Strings("arm64"),
+		Runtime:         jsii.String("provided.al2"),
+		Handler:         jsii.String("bootstrap"),
+		Description:     jsii.String("Queues synthetic users and checks they get matched"),
+		MemorySize:      jsii.Number(128),
+		Timeout:         jsii.Number(*cfg.Deadline + 10),
+		DependsOn:       &lambdaDependsOn,
+		TracingConfig: &LambdaFunctionTracingConfig{
+			Mode: cfg.tracing.Mode(),
+		},
+		Environment: &LambdaFunctionEnvironment{
+			Variables: &lambdaEnv,
+		},
+	})
+	common.Exempt(lambda, common.CHECK_DEAD_LETTERS, "scheduled by eventbridge, a failed run is missing data which the no-match alarm treats as breaching")
+
+	rule := NewCloudwatchEventRule(ctx.Scope, jsii.String(ctx.Id+"_rule"), &CloudwatchEventRuleConfig{
+		Provider:           ctx.Provider,
+		Name:               cfg.name,
+		Description:        jsii.String("Triggers a matchmaking canary on a schedule"),
+		IsEnabled:          jsii.Bool(true),
+		ScheduleExpression: cfg.Schedule,
+	})
+
+	NewCloudwatchEventTarget(ctx.Scope, jsii.String(ctx.Id+"_target"), &CloudwatchEventTargetConfig{
+		Provider: ctx.Provider,
+		Rule:     rule.Name(),
+		Arn:      lambda.Arn(),
+		RetryPolicy: &CloudwatchEventTargetRetryPolicy{
+			MaximumEventAgeInSeconds: jsii.Number(60),
+			MaximumRetryAttempts:     jsii.Number(0),
+		},
+	})
+
+	NewLambdaPermission(ctx.Scope, jsii.String(ctx.Id+"_perm"), &LambdaPermissionConfig{
+		Provider:     ctx.Provider,
+		StatementId:  jsii.String("AllowExecutionFromEventBridge"),
+		Action:       jsii.String("lambda:InvokeFunction"),
+		FunctionName: lambda.Arn(),
+		Principal:    jsii.String("events.amazonaws.com"),
+		SourceArn:    rule.Arn(),
+	})
+
+	actions := []*string{}
+	if topic, ok := cfg.topics[cfg.region]; ok {
+		actions = append(actions, topic)
+	}
+
+	// a canary that isn't reporting can't be trusted to be passing
+	// note: matches are made by whichever region holds the queue lock, so this isn't part of region health
+	alarm := NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(ctx.Id+"_alarm"), &CloudwatchMetricAlarmConfig{
+		Provider:           ctx.Provider,
+		AlarmName:          jsii.String(*cfg.name + "-no-match"),
+		AlarmDescription:   jsii.String(fmt.Sprintf("[%s/canary/no-match] - synthetic users queued in %s are not getting matched within %vs", *cfg.name, cfg.region, *cfg.Deadline)),
+		Namespace:          cfg.namespace,
+		MetricName:         jsii.String("CanarySuccess"),
+		Statistic:          jsii.String("Minimum"),
+		ComparisonOperator: jsii.String("LessThanThreshold"),
+		Threshold:          jsii.Number(1),
+		Period:             cfg.Period,
+		EvaluationPeriods:  cfg.EvaluationPeriods,
+		DatapointsToAlarm:  cfg.DatapointsToAlarm,
+		TreatMissingData:   jsii.String("breaching"),
+		AlarmActions:       &actions,
+		OkActions:          &actions,
+		Dimensions: &map[string]*string{
+			"Region": jsii.String(cfg.region),
+		},
+	})
+
+	return healthcheckCanaryInstance{Function: lambda, Rule: rule, Alarm: alarm}
+}
+
+func (app healthcheckCanary) AlarmIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance healthcheckCanaryInstance) common.ArnIdPair {
+		return common.ArnIdPair{Arn: instance.Alarm.Arn(), Id: instance.Alarm.AlarmName()}
+	})
+}
+
+func (app healthcheckCanary) FunctionIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance healthcheckCanaryInstance) common.ArnIdPair {
+		return common.FunctionToIdPair(instance.Function)
+	})
+}
@@ -19,6 +19,7 @@ type healthcheckCronInstance struct {
 type healthcheckCronConfig struct {
 	providers     common.Providers
 	name          *string
+	schedule      *string
 	healthchecker healthchecker
 }
 
@@ -46,7 +47,7 @@ func (cfg healthcheckCronInstanceConfig) new(ctx common.TfContext) healthcheckCr
 		Name:               cfg.name,
 		Description:        jsii.String("Triggers a healthcheck on a schedule"),
 		IsEnabled:          jsii.Bool(true),
-		ScheduleExpression: jsii.String("rate(1 minute)"),
+		ScheduleExpression: cfg.schedule,
 	})
 
 	lambdaArn := cfg.healthchecker.Regions[cfg.region].Function.Arn()
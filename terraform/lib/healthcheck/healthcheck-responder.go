@@ -6,7 +6,7 @@ import (
 
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
-	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchloggroup"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawss3object"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrole"
@@ -23,7 +23,8 @@ type healthcheckResponder struct {
 }
 
 type healthcheckResponderInstance struct {
-	Function LambdaFunction
+	Function      LambdaFunction
+	IteratorAlarm CloudwatchMetricAlarm
 }
 
 type healthcheckResponderConfig struct {
@@ -32,6 +33,9 @@ type healthcheckResponderConfig struct {
 	kmsReadPolicy *string
 	code          common.ObjectConfig
 	lambdaIam     common.LambdaIamConfig
+	tracing       common.LambdaTracingConfig
+	logs          common.LogGroupConfig
+	iteratorAge   common.IteratorAgeAlarmConfig
 	apiUrl        string
 	healthchecker healthchecker
 }
@@ -87,10 +91,11 @@ func (app healthcheckResponder) AddApiPerms(ctx common.TfContext, arns []*string
 
 func (cfg healthcheckResponderConfig) lambdaRole(ctx common.TfContext) IamRole {
 	lambdaRole := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
-		Provider:         ctx.Provider,
-		Name:             jsii.String(*cfg.name + "-lambda"),
-		Path:             cfg.lambdaIam.Path,
-		AssumeRolePolicy: cfg.lambdaIam.AssumeRole,
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.name + "-lambda"),
+		Path:                cfg.lambdaIam.Path,
+		AssumeRolePolicy:    cfg.lambdaIam.AssumeRole,
+		PermissionsBoundary: cfg.lambdaIam.PermissionsBoundary,
 	})
 
 	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_policy_exec"), &IamRolePolicyAttachmentConfig{
@@ -125,15 +130,25 @@ func (cfg healthcheckResponderConfig) lambdaRole(ctx common.TfContext) IamRole {
 		}).Json(),
 	})
 
+	common.FailureQueueConfig{}.AllowSend(common.SimpleContext(ctx.Scope, ctx.Id+"_failures", ctx.Provider), lambdaRole.Name(), cfg.name)
+
+	if cfg.tracing.Enabled {
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_xray"), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      lambdaRole.Name(),
+			PolicyArn: cfg.tracing.Policy,
+		})
+	}
+
 	return lambdaRole
 }
 
 func (cfg healthcheckResponderInstanceConfig) new(ctx common.TfContext) healthcheckResponderInstance {
-	logGroup := NewCloudwatchLogGroup(ctx.Scope, jsii.String(ctx.Id+"_logs"), &CloudwatchLogGroupConfig{
-		Provider:        ctx.Provider,
-		Name:            jsii.String("/aws/lambda/" + *cfg.name),
-		RetentionInDays: jsii.Number(7),
-	})
+	logGroup := cfg.logs.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_logs", ctx.Provider),
+		jsii.String("/aws/lambda/"+*cfg.name),
+		cfg.region,
+	)
 
 	code := NewDataAwsS3Object(ctx.Scope, jsii.String(ctx.Id+"_code"), &DataAwsS3ObjectConfig{
 		Provider: ctx.Provider,
@@ -163,10 +178,14 @@ func (cfg healthcheckResponderInstanceConfig) new(ctx common.TfContext) healthch
 		MemorySize:      jsii.Number(128),
 		Timeout:         jsii.Number(5),
 		DependsOn:       &lambdaDependsOn,
+		TracingConfig: &LambdaFunctionTracingConfig{
+			Mode: cfg.tracing.Mode(),
+		},
 		Environment: &LambdaFunctionEnvironment{
 			Variables: &lambdaEnv,
 		},
 	})
+	common.Exempt(lambda, common.CHECK_DEAD_LETTERS, "only invoked by its stream mapping, whose failed batches go to the failure queue")
 
 	filter := map[string]any{
 		// only inserts
@@ -182,6 +201,13 @@ func (cfg healthcheckResponderInstanceConfig) new(ctx common.TfContext) healthch
 	}
 	filterBytes, _ := json.Marshal(filter)
 
+	failures := common.FailureQueueConfig{Topics: cfg.iteratorAge.Topics}.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_failures", ctx.Provider),
+		cfg.name,
+		cfg.region,
+	)
+
+	// the default retries forever, a healthcheck is stale long before then
 	NewLambdaEventSourceMapping(ctx.Scope, jsii.String(ctx.Id+"_stream"), &LambdaEventSourceMappingConfig{
 		Provider:                       ctx.Provider,
 		FunctionName:                   lambda.FunctionName(),
@@ -189,6 +215,12 @@ func (cfg healthcheckResponderInstanceConfig) new(ctx common.TfContext) healthch
 		EventSourceArn:                 cfg.healthchecker.Regions[cfg.region].Table.StreamArn(),
 		StartingPosition:               jsii.String("LATEST"),
 		MaximumBatchingWindowInSeconds: jsii.Number(2),
+		MaximumRetryAttempts:           jsii.Number(2),
+		DestinationConfig: &LambdaEventSourceMappingDestinationConfig{
+			OnFailure: &LambdaEventSourceMappingDestinationConfigOnFailure{
+				DestinationArn: failures.Arn(),
+			},
+		},
 		FilterCriteria: &LambdaEventSourceMappingFilterCriteria{
 			Filter: &[]LambdaEventSourceMappingFilterCriteriaFilter{
 				{
@@ -198,5 +230,24 @@ func (cfg healthcheckResponderInstanceConfig) new(ctx common.TfContext) healthch
 		},
 	})
 
-	return healthcheckResponderInstance{lambda}
+	iteratorAlarm := cfg.iteratorAge.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_iterator_alarm", ctx.Provider),
+		cfg.name,
+		lambda.FunctionName(),
+		cfg.region,
+	)
+
+	return healthcheckResponderInstance{lambda, iteratorAlarm}
+}
+
+func (app healthcheckResponder) FunctionIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance healthcheckResponderInstance) common.ArnIdPair {
+		return common.FunctionToIdPair(instance.Function)
+	})
+}
+
+func (app healthcheckResponder) IteratorAgeAlarmIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance healthcheckResponderInstance) common.ArnIdPair {
+		return common.ArnIdPair{Arn: instance.IteratorAlarm.Arn(), Id: instance.IteratorAlarm.AlarmName()}
+	})
 }
@@ -1,11 +1,11 @@
 package healthcheck
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
-	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchloggroup"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsdynamodbtable"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawss3object"
@@ -20,6 +20,7 @@ import (
 type healthchecker struct {
 	Regions    map[string]healthcheckerInstance
 	LambdaRole IamRole
+	probe      bool
 }
 
 type healthcheckerInstance struct {
@@ -34,7 +35,12 @@ type healthcheckerConfig struct {
 	code           common.ObjectConfig
 	kmsArns        common.MultiRegionId
 	lambdaIam      common.LambdaIamConfig
+	tracing        common.LambdaTracingConfig
+	logs           common.LogGroupConfig
 	apiUrl         string
+	namespace      *string
+	// also check every other region's api from the outside
+	probe bool
 }
 
 type healthcheckerInstanceConfig struct {
@@ -113,14 +119,14 @@ func (cfg healthcheckerConfig) new(ctx common.TfContext) healthchecker {
 			Statement: []DataAwsIamPolicyDocumentStatement{
 				{
 					Effect:    jsii.String("Allow"),
-					Actions:   jsii.Strings("dynamodb:UpdateItem", "dynamodb:ConditionCheckItem"),
+					Actions:   jsii.Strings("dynamodb:UpdateItem", "dynamodb:ConditionCheckItem", "dynamodb:PutItem"),
 					Resources: &tableArns,
 				},
 			},
 		}).Json(),
 	})
 
-	return healthchecker{instances, lambdaRole}
+	return healthchecker{instances, lambdaRole, cfg.probe}
 }
 
 func (app healthchecker) AddApiPerms(ctx common.TfContext, arns []*string) {
@@ -131,6 +137,9 @@ func (app healthchecker) AddApiPerms(ctx common.TfContext, arns []*string) {
 			jsii.String(*arn + "/types/Subscription/fields/healthcheck"),
 			jsii.String(*arn + "/types/HealthNotification/fields/*"),
 		}...)
+		if app.probe {
+			resources = append(resources, jsii.String(*arn+"/types/Query/fields/region"))
+		}
 	}
 
 	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_lambda_role_appsync_policy"), &IamRolePolicyConfig{
@@ -151,10 +160,11 @@ func (app healthchecker) AddApiPerms(ctx common.TfContext, arns []*string) {
 
 func (cfg healthcheckerConfig) lambdaRole(ctx common.TfContext) IamRole {
 	lambdaRole := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
-		Provider:         ctx.Provider,
-		Name:             jsii.String(*cfg.name + "-lambda"),
-		Path:             cfg.lambdaIam.Path,
-		AssumeRolePolicy: cfg.lambdaIam.AssumeRole,
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.name + "-lambda"),
+		Path:                cfg.lambdaIam.Path,
+		AssumeRolePolicy:    cfg.lambdaIam.AssumeRole,
+		PermissionsBoundary: cfg.lambdaIam.PermissionsBoundary,
 	})
 
 	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_policy_exec"), &IamRolePolicyAttachmentConfig{
@@ -169,15 +179,48 @@ func (cfg healthcheckerConfig) lambdaRole(ctx common.TfContext) IamRole {
 		PolicyArn: cfg.kmsWritePolicy,
 	})
 
+	if cfg.tracing.Enabled {
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_xray"), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      lambdaRole.Name(),
+			PolicyArn: cfg.tracing.Policy,
+		})
+	}
+
+	// probe results are reported to the probed region's cloudwatch
+	if cfg.probe {
+		NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_metrics_policy"), &IamRolePolicyConfig{
+			Provider: ctx.Provider,
+			Name:     jsii.String("metrics"),
+			Role:     lambdaRole.Name(),
+			Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_metrics_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+				Statement: []DataAwsIamPolicyDocumentStatement{
+					{
+						Effect:    jsii.String("Allow"),
+						Actions:   jsii.Strings("cloudwatch:PutMetricData"),
+						Resources: jsii.Strings("*"),
+						Condition: []DataAwsIamPolicyDocumentStatementCondition{
+							{
+								Test:     jsii.String("StringEquals"),
+								Variable: jsii.String("cloudwatch:namespace"),
+								Values:   jsii.Strings(*cfg.namespace),
+							},
+						},
+					},
+				},
+			}).Json(),
+		})
+	}
+
 	return lambdaRole
 }
 
 func (cfg healthcheckerInstanceConfig) new(ctx common.TfContext) healthcheckerInstance {
-	logGroup := NewCloudwatchLogGroup(ctx.Scope, jsii.String(ctx.Id+"_logs"), &CloudwatchLogGroupConfig{
-		Provider:        ctx.Provider,
-		Name:            jsii.String("/aws/lambda/" + *cfg.name),
-		RetentionInDays: jsii.Number(7),
-	})
+	logGroup := cfg.logs.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_logs", ctx.Provider),
+		jsii.String("/aws/lambda/"+*cfg.name),
+		cfg.region,
+	)
 
 	table := NewDataAwsDynamodbTable(ctx.Scope, jsii.String(ctx.Id+"_table"), &DataAwsDynamodbTableConfig{
 		Provider: ctx.Provider,
@@ -190,9 +233,22 @@ func (cfg healthcheckerInstanceConfig) new(ctx common.TfContext) healthcheckerIn
 		Key:      cfg.code.ToKey("rust/target/lambda/healthcheck/bootstrap.zip"),
 	})
 
+	// <region>=<url> for every other region, sorted so the env doesn't churn
+	probeUrls := []string{}
+	if cfg.probe {
+		for region := range cfg.providers {
+			if region != cfg.region {
+				probeUrls = append(probeUrls, region+"="+strings.Replace(cfg.apiUrl, "<region>", region, -1))
+			}
+		}
+		sort.Strings(probeUrls)
+	}
+
 	lambdaEnv := map[string]*string{
-		"TABLE":   table.Id(),
-		"API_URL": jsii.String(strings.Replace(cfg.apiUrl, "<region>", cfg.region, -1)),
+		"TABLE":            table.Id(),
+		"API_URL":          jsii.String(strings.Replace(cfg.apiUrl, "<region>", cfg.region, -1)),
+		"METRIC_NAMESPACE": cfg.namespace,
+		"PROBE_URLS":       jsii.String(strings.Join(probeUrls, ",")),
 	}
 
 	lambdaDependsOn := []cdktf.ITerraformDependable{
@@ -213,10 +269,14 @@ func (cfg healthcheckerInstanceConfig) new(ctx common.TfContext) healthcheckerIn
 		MemorySize:      jsii.Number(128),
 		Timeout:         jsii.Number(20),
 		DependsOn:       &lambdaDependsOn,
+		TracingConfig: &LambdaFunctionTracingConfig{
+			Mode: cfg.tracing.Mode(),
+		},
 		Environment: &LambdaFunctionEnvironment{
 			Variables: &lambdaEnv,
 		},
 	})
+	common.Exempt(lambda, common.CHECK_DEAD_LETTERS, "scheduled by eventbridge, a failed run is a missed healthcheck which the healthcheck alarm already covers")
 
 	return healthcheckerInstance{lambda, table}
 }
@@ -226,3 +286,9 @@ func (app healthchecker) TableIds() map[string]common.ArnIdPair {
 		return common.TableToIdPair(instance.Table)
 	})
 }
+
+func (app healthchecker) FunctionIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance healthcheckerInstance) common.ArnIdPair {
+		return common.FunctionToIdPair(instance.Function)
+	})
+}
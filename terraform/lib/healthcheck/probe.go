@@ -0,0 +1,104 @@
+package healthcheck
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/provider"
+)
+
+type healthcheckProbeAlarm struct {
+	Regions map[string]CloudwatchMetricAlarm
+}
+
+type HealthcheckProbeConfig struct {
+	Enabled bool
+	// other regions that must fail to reach a region before it alarms, 0 means a majority
+	Quorum            int
+	EvaluationPeriods *float64
+	DatapointsToAlarm *float64
+}
+
+type healthcheckProbeAlarmConfig struct {
+	HealthcheckProbeConfig
+	providers common.Providers
+	name      *string
+	namespace *string
+	// alarm/ok notifications, keyed by region
+	topics map[string]*string
+}
+
+type healthcheckProbeAlarmInstanceConfig struct {
+	healthcheckProbeAlarmConfig
+	region  string
+	probers []string
+	quorum  int
+}
+
+func (cfg healthcheckProbeAlarmConfig) new(ctx common.TfContext) healthcheckProbeAlarm {
+	regions := common.Object[AwsProvider](cfg.providers).Keys()
+	sort.Strings(regions)
+
+	// create an alarm in each region, watching what every other region reported about it
+	instances := map[string]CloudwatchMetricAlarm{}
+	for region, provider := range cfg.providers {
+		probers := []string{}
+		for _, prober := range regions {
+			if prober != region {
+				probers = append(probers, prober)
+			}
+		}
+
+		quorum := cfg.Quorum
+		if quorum <= 0 || quorum > len(probers) {
+			quorum = len(probers)/2 + 1
+		}
+
+		instances[region] = healthcheckProbeAlarmInstanceConfig{
+			healthcheckProbeAlarmConfig: cfg,
+			region:                      region,
+			probers:                     probers,
+			quorum:                      quorum,
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider))
+	}
+
+	return healthcheckProbeAlarm{instances}
+}
+
+func (cfg healthcheckProbeAlarmInstanceConfig) new(ctx common.TfContext) CloudwatchMetricAlarm {
+	actions := []*string{}
+	if topic, ok := cfg.topics[cfg.region]; ok {
+		actions = append(actions, topic)
+	}
+
+	// every prober also reports without its own region, so the sum is how many failed to get through (no metric math for route53)
+	// probers that stop reporting are their own problem, they don't count against this region
+	return NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(ctx.Id), &CloudwatchMetricAlarmConfig{
+		Provider:           ctx.Provider,
+		AlarmName:          jsii.String(*cfg.name + "-unreachable"),
+		AlarmDescription:   jsii.String(fmt.Sprintf("[%s/probes/unreachable] - %s is unreachable from at least %d of %d other regions", *cfg.name, cfg.region, cfg.quorum, len(cfg.probers))),
+		Namespace:          cfg.namespace,
+		MetricName:         jsii.String("ProbeFailure"),
+		Statistic:          jsii.String("Sum"),
+		Period:             jsii.Number(60),
+		ComparisonOperator: jsii.String("GreaterThanOrEqualToThreshold"),
+		Threshold:          jsii.Number(float64(cfg.quorum)),
+		EvaluationPeriods:  cfg.EvaluationPeriods,
+		DatapointsToAlarm:  cfg.DatapointsToAlarm,
+		TreatMissingData:   jsii.String("notBreaching"),
+		AlarmActions:       &actions,
+		OkActions:          &actions,
+		Dimensions: &map[string]*string{
+			"ProbedRegion": jsii.String(cfg.region),
+		},
+	})
+}
+
+func (app healthcheckProbeAlarm) AlarmIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(alarm CloudwatchMetricAlarm) common.ArnIdPair {
+		return common.ArnIdPair{Arn: alarm.Arn(), Id: alarm.AlarmName()}
+	})
+}
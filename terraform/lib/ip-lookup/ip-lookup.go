@@ -3,7 +3,6 @@ package ip_lookup
 import (
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
-	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchloggroup"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawss3object"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawssecretsmanagersecret"
@@ -32,6 +31,10 @@ type IpLookupConfig struct {
 	Code          common.ObjectConfig
 	KmsArns       common.MultiRegionId
 	LambdaIam     common.LambdaIamConfig
+	Tracing       common.LambdaTracingConfig
+	Logs          common.LogGroupConfig
+	// the secret holds a token that has to be re-issued if it's lost
+	DeletionProtection bool
 }
 
 type instanceConfig struct {
@@ -42,6 +45,8 @@ type instanceConfig struct {
 }
 
 func (cfg IpLookupConfig) New(ctx common.TfContext) ipLookup {
+	common.TagComponent(ctx, common.COMPONENT_IP_LOOKUP)
+
 	// create secret w/ replicas in each region
 	secretReplicas := []SecretsmanagerSecretReplica{}
 	for region, arn := range cfg.KmsArns.Replicas {
@@ -57,6 +62,7 @@ func (cfg IpLookupConfig) New(ctx common.TfContext) ipLookup {
 		Description: jsii.String("API token for ip lookup service"),
 		KmsKeyId:    cfg.KmsArns.Primary,
 		Replica:     &secretReplicas,
+		Lifecycle:   common.Protect(cfg.DeletionProtection),
 	})
 
 	// create lambda role
@@ -99,10 +105,11 @@ func (cfg IpLookupConfig) New(ctx common.TfContext) ipLookup {
 
 func (cfg IpLookupConfig) lambdaRole(ctx common.TfContext) IamRole {
 	lambdaRole := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
-		Provider:         ctx.Provider,
-		Name:             jsii.String(*cfg.Name + "-lambda"),
-		Path:             cfg.LambdaIam.Path,
-		AssumeRolePolicy: cfg.LambdaIam.AssumeRole,
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.Name + "-lambda"),
+		Path:                cfg.LambdaIam.Path,
+		AssumeRolePolicy:    cfg.LambdaIam.AssumeRole,
+		PermissionsBoundary: cfg.LambdaIam.PermissionsBoundary,
 	})
 
 	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_policy_exec"), &IamRolePolicyAttachmentConfig{
@@ -117,15 +124,23 @@ func (cfg IpLookupConfig) lambdaRole(ctx common.TfContext) IamRole {
 		PolicyArn: cfg.KmsReadPolicy,
 	})
 
+	if cfg.Tracing.Enabled {
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_xray"), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      lambdaRole.Name(),
+			PolicyArn: cfg.Tracing.Policy,
+		})
+	}
+
 	return lambdaRole
 }
 
 func (cfg instanceConfig) new(ctx common.TfContext) ipLookupInstance {
-	logGroup := NewCloudwatchLogGroup(ctx.Scope, jsii.String(ctx.Id+"_logs"), &CloudwatchLogGroupConfig{
-		Provider:        ctx.Provider,
-		Name:            jsii.String("/aws/lambda/" + *cfg.Name),
-		RetentionInDays: jsii.Number(7),
-	})
+	logGroup := cfg.Logs.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_logs", ctx.Provider),
+		jsii.String("/aws/lambda/"+*cfg.Name),
+		cfg.region,
+	)
 
 	// note: this will fail on initial deploy to a new replica region
 	// not sure how to fix this short of sleeping in a provisioner/null resource
@@ -162,10 +177,14 @@ func (cfg instanceConfig) new(ctx common.TfContext) ipLookupInstance {
 		MemorySize:      jsii.Number(128),
 		Timeout:         jsii.Number(3),
 		DependsOn:       &lambdaDependsOn,
+		TracingConfig: &LambdaFunctionTracingConfig{
+			Mode: cfg.Tracing.Mode(),
+		},
 		Environment: &LambdaFunctionEnvironment{
 			Variables: &lambdaEnv,
 		},
 	})
+	common.Exempt(lambda, common.CHECK_DEAD_LETTERS, "only invoked by the appsync ip_lookup data source, whose request template uses a synchronous Invoke")
 
 	return ipLookupInstance{lambda, secret}
 }
@@ -0,0 +1,238 @@
+package lock_table
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatcheventrule"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatcheventtarget"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawss3object"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrole"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicy"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicyattachment"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/lambdafunction"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/lambdapermission"
+	"github.com/hashicorp/terraform-cdk-go/cdktf"
+)
+
+// how long the checker waits on a replica before calling it failed
+const lockCheckTimeout = 20
+
+// replicas lagging behind this long can't be trusted to arbitrate the lock
+const lockCheckMaxLagMs = 5000
+
+type lockCheck struct {
+	Regions    map[string]lockCheckInstance
+	LambdaRole IamRole
+}
+
+type lockCheckInstance struct {
+	Function LambdaFunction
+	Rule     CloudwatchEventRule
+	Alarm    CloudwatchMetricAlarm
+}
+
+type lockCheckConfig struct {
+	LockTableConfig
+	tables lockTable
+}
+
+type lockCheckInstanceConfig struct {
+	lockCheckConfig
+	region string
+	role   *string
+}
+
+func (cfg lockCheckConfig) new(ctx common.TfContext) lockCheck {
+	// create lambda role
+	lambdaRole := cfg.lambdaRole(common.SimpleContext(ctx.Scope, ctx.Id+"_lambda_role", ctx.Provider))
+
+	// create an instance of the service in each region
+	instances := map[string]lockCheckInstance{}
+	for region, provider := range cfg.Providers {
+		instances[region] = lockCheckInstanceConfig{
+			lockCheckConfig: cfg,
+			region:          region,
+			role:            lambdaRole.Arn(),
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider))
+	}
+
+	return lockCheck{instances, lambdaRole}
+}
+
+func (cfg lockCheckConfig) lambdaRole(ctx common.TfContext) IamRole {
+	lambdaRole := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.Name + "-check-lambda"),
+		Path:                cfg.LambdaIam.Path,
+		AssumeRolePolicy:    cfg.LambdaIam.AssumeRole,
+		PermissionsBoundary: cfg.LambdaIam.PermissionsBoundary,
+	})
+
+	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_policy_exec"), &IamRolePolicyAttachmentConfig{
+		Provider:  ctx.Provider,
+		Role:      lambdaRole.Name(),
+		PolicyArn: cfg.LambdaIam.ExecPolicy,
+	})
+
+	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_kms"), &IamRolePolicyAttachmentConfig{
+		Provider:  ctx.Provider,
+		Role:      lambdaRole.Name(),
+		PolicyArn: cfg.KmsWritePolicy,
+	})
+
+	if cfg.Tracing.Enabled {
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_xray"), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      lambdaRole.Name(),
+			PolicyArn: cfg.Tracing.Policy,
+		})
+	}
+
+	// probes are written locally but read back from every replica
+	tableArns := common.ArnsToList(cfg.tables.TableIds())
+
+	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_custom_policy"), &IamRolePolicyConfig{
+		Provider: ctx.Provider,
+		Name:     jsii.String("lock-check"),
+		Role:     lambdaRole.Name(),
+		Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_custom_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("dynamodb:GetItem", "dynamodb:PutItem"),
+					Resources: &tableArns,
+				},
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("cloudwatch:PutMetricData"),
+					Resources: jsii.Strings("*"),
+					Condition: []DataAwsIamPolicyDocumentStatementCondition{
+						{
+							Test:     jsii.String("StringEquals"),
+							Variable: jsii.String("cloudwatch:namespace"),
+							Values:   jsii.Strings(*cfg.MetricNamespace),
+						},
+					},
+				},
+			},
+		}).Json(),
+	})
+
+	return lambdaRole
+}
+
+func (cfg lockCheckInstanceConfig) new(ctx common.TfContext) lockCheckInstance {
+	name := jsii.String(*cfg.Name + "-check")
+
+	logGroup := cfg.Logs.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_logs", ctx.Provider),
+		jsii.String("/aws/lambda/"+*name),
+		cfg.region,
+	)
+
+	code := NewDataAwsS3Object(ctx.Scope, jsii.String(ctx.Id+"_code"), &DataAwsS3ObjectConfig{
+		Provider: ctx.Provider,
+		Bucket:   cfg.Code.ToBucket(cfg.region),
+		Key:      cfg.Code.ToKey("rust/target/lambda/lock-check/bootstrap.zip"),
+	})
+
+	lambdaEnv := map[string]*string{
+		"LOCK_TABLE":       cfg.tables.Regions[cfg.region].Table.Id(),
+		"LOCK_REGIONS":     jsii.String(strings.Join(cfg.Regions, ",")),
+		"METRIC_NAMESPACE": cfg.MetricNamespace,
+		"TIMEOUT_SECONDS":  jsii.String(fmt.Sprint(lockCheckTimeout)),
+	}
+
+	lambdaDependsOn := []cdktf.ITerraformDependable{
+		logGroup,
+	}
+
+	lambda := NewLambdaFunction(ctx.Scope, jsii.String(ctx.Id+"_lambda"), &LambdaFunctionConfig{
+		Provider:        ctx.Provider,
+		FunctionName:    name,
+		Role:            cfg.role,
+		S3Bucket:        code.Bucket(),
+		S3Key:           code.Key(),
+		S3ObjectVersion: code.VersionId(),
+		Architectures:   jsii.Strings("arm64"),
+		Runtime:         jsii.String("provided.al2"),
+		Handler:         jsii.String("bootstrap"),
+		Description:     jsii.String("Verifies lock table replicas agree with each other"),
+		MemorySize:      jsii.Number(128),
+		Timeout:         jsii.Number(lockCheckTimeout + 10),
+		DependsOn:       &lambdaDependsOn,
+		TracingConfig: &LambdaFunctionTracingConfig{
+			Mode: cfg.Tracing.Mode(),
+		},
+		Environment: &LambdaFunctionEnvironment{
+			Variables: &lambdaEnv,
+		},
+	})
+	common.Exempt(lambda, common.CHECK_DEAD_LETTERS, "scheduled by eventbridge, a failed run is missing data which the replication alarm treats as breaching")
+
+	rule := NewCloudwatchEventRule(ctx.Scope, jsii.String(ctx.Id+"_rule"), &CloudwatchEventRuleConfig{
+		Provider:           ctx.Provider,
+		Name:               name,
+		Description:        jsii.String("Triggers a lock table replication check on a schedule"),
+		IsEnabled:          jsii.Bool(true),
+		ScheduleExpression: jsii.String("rate(1 minute)"),
+	})
+
+	NewCloudwatchEventTarget(ctx.Scope, jsii.String(ctx.Id+"_target"), &CloudwatchEventTargetConfig{
+		Provider: ctx.Provider,
+		Rule:     rule.Name(),
+		Arn:      lambda.Arn(),
+		RetryPolicy: &CloudwatchEventTargetRetryPolicy{
+			MaximumEventAgeInSeconds: jsii.Number(60),
+			MaximumRetryAttempts:     jsii.Number(0),
+		},
+	})
+
+	NewLambdaPermission(ctx.Scope, jsii.String(ctx.Id+"_perm"), &LambdaPermissionConfig{
+		Provider:     ctx.Provider,
+		StatementId:  jsii.String("AllowExecutionFromEventBridge"),
+		Action:       jsii.String("lambda:InvokeFunction"),
+		FunctionName: lambda.Arn(),
+		Principal:    jsii.String("events.amazonaws.com"),
+		SourceArn:    rule.Arn(),
+	})
+
+	// a checker that isn't reporting is as bad as a lagging replica
+	// note: processors only look at alarms in their own region, which is fine as lag tends to go both ways
+	alarm := NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(ctx.Id+"_alarm"), &CloudwatchMetricAlarmConfig{
+		Provider:           ctx.Provider,
+		AlarmName:          jsii.String(*cfg.Name + "-replication"),
+		AlarmDescription:   jsii.String(fmt.Sprintf("[%s/replication-lag/lock-unreliable] - lock table replicas are not agreeing with %s", *cfg.Name, cfg.region)),
+		Namespace:          cfg.MetricNamespace,
+		MetricName:         jsii.String("MaxReplicationLag"),
+		Statistic:          jsii.String("Maximum"),
+		ComparisonOperator: jsii.String("GreaterThanOrEqualToThreshold"),
+		Threshold:          jsii.Number(lockCheckMaxLagMs),
+		EvaluationPeriods:  jsii.Number(3),
+		Period:             jsii.Number(60),
+		DatapointsToAlarm:  jsii.Number(2),
+		TreatMissingData:   jsii.String("breaching"),
+		Dimensions: &map[string]*string{
+			"Region": jsii.String(cfg.region),
+		},
+	})
+
+	return lockCheckInstance{Function: lambda, Rule: rule, Alarm: alarm}
+}
+
+func (app lockCheck) AlarmIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance lockCheckInstance) common.ArnIdPair {
+		return common.ArnIdPair{Arn: instance.Alarm.Arn(), Id: instance.Alarm.AlarmName()}
+	})
+}
+
+func (app lockCheck) FunctionIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance lockCheckInstance) common.ArnIdPair {
+		return common.FunctionToIdPair(instance.Function)
+	})
+}
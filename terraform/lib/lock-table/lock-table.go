@@ -9,6 +9,7 @@ import (
 
 type lockTable struct {
 	Regions map[string]lockTableInstance
+	Checker lockCheck
 }
 
 type lockTableInstance struct {
@@ -16,9 +17,20 @@ type lockTableInstance struct {
 }
 
 type LockTableConfig struct {
-	Providers common.Providers
-	Name      *string
-	KmsArns   common.MultiRegionId
+	Providers       common.Providers
+	Name            *string
+	KmsArns         common.MultiRegionId
+	KmsWritePolicy  *string
+	Code            common.ObjectConfig
+	LambdaIam       common.LambdaIamConfig
+	Tracing         common.LambdaTracingConfig
+	Logs            common.LogGroupConfig
+	MetricNamespace *string
+	// applies to every replica
+	PointInTimeRecovery bool
+	DeletionProtection  bool
+	// every region in lock order
+	Regions []string
 }
 
 type instanceConfig struct {
@@ -28,13 +40,16 @@ type instanceConfig struct {
 }
 
 func (cfg LockTableConfig) New(ctx common.TfContext) lockTable {
+	common.TagComponent(ctx, common.COMPONENT_LOCK_TABLE)
+
 	// create tables w/ replicas in each region
 	tableReplicas := []DynamodbTableReplica{}
 	for region, arn := range cfg.KmsArns.Replicas {
 		tableReplicas = append(tableReplicas, DynamodbTableReplica{
-			RegionName:    jsii.String(region),
-			KmsKeyArn:     arn,
-			PropagateTags: jsii.Bool(true),
+			RegionName:          jsii.String(region),
+			KmsKeyArn:           arn,
+			PropagateTags:       jsii.Bool(true),
+			PointInTimeRecovery: jsii.Bool(cfg.PointInTimeRecovery),
 		})
 	}
 
@@ -48,10 +63,14 @@ func (cfg LockTableConfig) New(ctx common.TfContext) lockTable {
 		StreamEnabled:  jsii.Bool(true),
 		StreamViewType: jsii.String("NEW_AND_OLD_IMAGES"),
 		Replica:        &tableReplicas,
+		Lifecycle:      common.Protect(cfg.DeletionProtection),
 		ServerSideEncryption: &DynamodbTableServerSideEncryption{
 			Enabled:   jsii.Bool(true),
 			KmsKeyArn: cfg.KmsArns.Primary,
 		},
+		PointInTimeRecovery: &DynamodbTablePointInTimeRecovery{
+			Enabled: jsii.Bool(cfg.PointInTimeRecovery),
+		},
 		Ttl: &DynamodbTableTtl{
 			Enabled:       jsii.Bool(true),
 			AttributeName: jsii.String("ttl"),
@@ -78,7 +97,15 @@ func (cfg LockTableConfig) New(ctx common.TfContext) lockTable {
 		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider))
 	}
 
-	return lockTable{instances}
+	result := lockTable{Regions: instances}
+
+	// make sure the replicas actually agree with each other
+	result.Checker = lockCheckConfig{
+		LockTableConfig: cfg,
+		tables:          result,
+	}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_check", ctx.Provider))
+
+	return result
 }
 
 func (cfg instanceConfig) new(ctx common.TfContext) lockTableInstance {
@@ -0,0 +1,329 @@
+package log_archive
+
+import (
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchlogdestination"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchlogdestinationpolicy"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrole"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/iamrolepolicy"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/kinesisfirehosedeliverystream"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucket"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketlifecycleconfiguration"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketpolicy"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketpublicaccessblock"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/s3bucketserversideencryptionconfiguration"
+)
+
+type logArchive struct {
+	Bucket         S3Bucket
+	DeliveryStream KinesisFirehoseDeliveryStream
+	// log groups can only subscribe to a destination in their own region
+	Destinations map[string]CloudwatchLogDestination
+}
+
+type LogArchiveConfig struct {
+	Providers common.Providers
+	Name      *string
+	IamPath   *string
+	AccountId *string
+	// nil means roles are created without a boundary
+	PermissionsBoundary *string
+	// the bucket lives with the primary key
+	KmsArns common.MultiRegionId
+	// days before objects move to cheaper storage, and before they're deleted
+	InfrequentAccessDays *float64
+	GlacierDays          *float64
+	ExpirationDays       *float64
+}
+
+type destinationConfig struct {
+	LogArchiveConfig
+	region string
+	role   *string
+	stream *string
+}
+
+func (cfg LogArchiveConfig) New(ctx common.TfContext) logArchive {
+	common.TagComponent(ctx, common.COMPONENT_LOG_ARCHIVE)
+
+	bucket := cfg.bucket(common.SimpleContext(ctx.Scope, ctx.Id+"_bucket", ctx.Provider))
+
+	firehoseRole := cfg.firehoseRole(common.SimpleContext(ctx.Scope, ctx.Id+"_firehose_role", ctx.Provider), bucket)
+
+	// logs arrive gzipped from cloudwatch already, so no compression on top
+	stream := NewKinesisFirehoseDeliveryStream(ctx.Scope, jsii.String(ctx.Id+"_stream"), &KinesisFirehoseDeliveryStreamConfig{
+		Provider:    ctx.Provider,
+		Name:        jsii.String(*cfg.Name + "-log-archive"),
+		Destination: jsii.String("extended_s3"),
+		ServerSideEncryption: &KinesisFirehoseDeliveryStreamServerSideEncryption{
+			Enabled: jsii.Bool(true),
+			KeyType: jsii.String("AWS_OWNED_CMK"),
+		},
+		ExtendedS3Configuration: &KinesisFirehoseDeliveryStreamExtendedS3Configuration{
+			RoleArn:           firehoseRole.Arn(),
+			BucketArn:         bucket.Arn(),
+			KmsKeyArn:         cfg.KmsArns.Primary,
+			Prefix:            jsii.String("logs/!{timestamp:yyyy/MM/dd}/"),
+			ErrorOutputPrefix: jsii.String("errors/!{firehose:error-output-type}/!{timestamp:yyyy/MM/dd}/"),
+			CompressionFormat: jsii.String("UNCOMPRESSED"),
+			BufferSize:        jsii.Number(5),
+			BufferInterval:    jsii.Number(300),
+		},
+	})
+
+	destinationRole := cfg.destinationRole(common.SimpleContext(ctx.Scope, ctx.Id+"_destination_role", ctx.Provider), stream)
+
+	// create a destination in each region, all feeding the one stream
+	destinations := map[string]CloudwatchLogDestination{}
+	for region, provider := range cfg.Providers {
+		destinations[region] = destinationConfig{
+			LogArchiveConfig: cfg,
+			region:           region,
+			role:             destinationRole.Arn(),
+			stream:           stream.Arn(),
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider))
+	}
+
+	return logArchive{Bucket: bucket, DeliveryStream: stream, Destinations: destinations}
+}
+
+func (cfg LogArchiveConfig) bucket(ctx common.TfContext) S3Bucket {
+	// bucket names are global, the account id keeps stacks from colliding
+	bucket := NewS3Bucket(ctx.Scope, jsii.String(ctx.Id), &S3BucketConfig{
+		Provider: ctx.Provider,
+		Bucket:   jsii.String(*cfg.Name + "-log-archive-" + *cfg.AccountId),
+	})
+
+	NewS3BucketPublicAccessBlock(ctx.Scope, jsii.String(ctx.Id+"_public_access"), &S3BucketPublicAccessBlockConfig{
+		Provider:              ctx.Provider,
+		Bucket:                bucket.Id(),
+		BlockPublicAcls:       jsii.Bool(true),
+		BlockPublicPolicy:     jsii.Bool(true),
+		IgnorePublicAcls:      jsii.Bool(true),
+		RestrictPublicBuckets: jsii.Bool(true),
+	})
+
+	NewS3BucketServerSideEncryptionConfigurationA(ctx.Scope, jsii.String(ctx.Id+"_encryption"), &S3BucketServerSideEncryptionConfigurationAConfig{
+		Provider: ctx.Provider,
+		Bucket:   bucket.Id(),
+		Rule: []S3BucketServerSideEncryptionConfigurationRuleA{
+			{
+				// bucket keys save a kms call per object
+				BucketKeyEnabled: jsii.Bool(true),
+				ApplyServerSideEncryptionByDefault: &S3BucketServerSideEncryptionConfigurationRuleApplyServerSideEncryptionByDefaultA{
+					SseAlgorithm:   jsii.String("aws:kms"),
+					KmsMasterKeyId: cfg.KmsArns.Primary,
+				},
+			},
+		},
+	})
+
+	NewS3BucketLifecycleConfiguration(ctx.Scope, jsii.String(ctx.Id+"_lifecycle"), &S3BucketLifecycleConfigurationConfig{
+		Provider: ctx.Provider,
+		Bucket:   bucket.Id(),
+		Rule: []S3BucketLifecycleConfigurationRule{
+			{
+				Id:     jsii.String("archive"),
+				Status: jsii.String("Enabled"),
+				Filter: &S3BucketLifecycleConfigurationRuleFilter{
+					Prefix: jsii.String(""),
+				},
+				Transition: []S3BucketLifecycleConfigurationRuleTransition{
+					{
+						Days:         cfg.InfrequentAccessDays,
+						StorageClass: jsii.String("STANDARD_IA"),
+					},
+					{
+						Days:         cfg.GlacierDays,
+						StorageClass: jsii.String("GLACIER"),
+					},
+				},
+				Expiration: &S3BucketLifecycleConfigurationRuleExpiration{
+					Days: cfg.ExpirationDays,
+				},
+				AbortIncompleteMultipartUpload: &S3BucketLifecycleConfigurationRuleAbortIncompleteMultipartUpload{
+					DaysAfterInitiation: jsii.Number(1),
+				},
+			},
+		},
+	})
+
+	NewS3BucketPolicy(ctx.Scope, jsii.String(ctx.Id+"_policy"), &S3BucketPolicyConfig{
+		Provider: ctx.Provider,
+		Bucket:   bucket.Id(),
+		Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Sid:       jsii.String("DenyInsecureTransport"),
+					Effect:    jsii.String("Deny"),
+					Actions:   jsii.Strings("s3:*"),
+					Resources: jsii.Strings(*bucket.Arn(), *bucket.Arn()+"/*"),
+					Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+						{
+							Type:        jsii.String("*"),
+							Identifiers: jsii.Strings("*"),
+						},
+					},
+					Condition: []DataAwsIamPolicyDocumentStatementCondition{
+						{
+							Test:     jsii.String("Bool"),
+							Variable: jsii.String("aws:SecureTransport"),
+							Values:   jsii.Strings("false"),
+						},
+					},
+				},
+			},
+		}).Json(),
+	})
+
+	return bucket
+}
+
+func (cfg LogArchiveConfig) firehoseRole(ctx common.TfContext, bucket S3Bucket) IamRole {
+	role := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.Name + "-log-archive-firehose"),
+		Path:                cfg.IamPath,
+		PermissionsBoundary: cfg.PermissionsBoundary,
+		AssumeRolePolicy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_assume_role"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:  jsii.String("Allow"),
+					Actions: jsii.Strings("sts:AssumeRole"),
+					Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+						{
+							Type:        jsii.String("Service"),
+							Identifiers: jsii.Strings("firehose.amazonaws.com"),
+						},
+					},
+					Condition: []DataAwsIamPolicyDocumentStatementCondition{
+						{
+							Test:     jsii.String("StringEquals"),
+							Variable: jsii.String("sts:ExternalId"),
+							Values:   jsii.Strings(*cfg.AccountId),
+						},
+					},
+				},
+			},
+		}).Json(),
+	})
+
+	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_policy"), &IamRolePolicyConfig{
+		Provider: ctx.Provider,
+		Name:     jsii.String("delivery"),
+		Role:     role.Name(),
+		Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("s3:AbortMultipartUpload", "s3:GetBucketLocation", "s3:GetObject", "s3:ListBucket", "s3:ListBucketMultipartUploads", "s3:PutObject"),
+					Resources: jsii.Strings(*bucket.Arn(), *bucket.Arn()+"/*"),
+				},
+				// only through s3, objects are encrypted with the primary key
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("kms:Decrypt", "kms:GenerateDataKey"),
+					Resources: jsii.Strings(*cfg.KmsArns.Primary),
+					Condition: []DataAwsIamPolicyDocumentStatementCondition{
+						{
+							Test:     jsii.String("StringLike"),
+							Variable: jsii.String("kms:ViaService"),
+							Values:   jsii.Strings("s3.*.amazonaws.com"),
+						},
+					},
+				},
+			},
+		}).Json(),
+	})
+
+	return role
+}
+
+func (cfg LogArchiveConfig) destinationRole(ctx common.TfContext, stream KinesisFirehoseDeliveryStream) IamRole {
+	role := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.Name + "-log-archive-destination"),
+		Path:                cfg.IamPath,
+		PermissionsBoundary: cfg.PermissionsBoundary,
+		AssumeRolePolicy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_assume_role"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:  jsii.String("Allow"),
+					Actions: jsii.Strings("sts:AssumeRole"),
+					Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+						{
+							Type:        jsii.String("Service"),
+							Identifiers: jsii.Strings("logs.amazonaws.com"),
+						},
+					},
+					Condition: []DataAwsIamPolicyDocumentStatementCondition{
+						{
+							Test:     jsii.String("StringLike"),
+							Variable: jsii.String("aws:SourceArn"),
+							Values:   jsii.Strings("arn:aws:logs:*:" + *cfg.AccountId + ":*"),
+						},
+					},
+				},
+			},
+		}).Json(),
+	})
+
+	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_policy"), &IamRolePolicyConfig{
+		Provider: ctx.Provider,
+		Name:     jsii.String("firehose"),
+		Role:     role.Name(),
+		Policy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("firehose:PutRecord", "firehose:PutRecordBatch"),
+					Resources: jsii.Strings(*stream.Arn()),
+				},
+			},
+		}).Json(),
+	})
+
+	return role
+}
+
+func (cfg destinationConfig) new(ctx common.TfContext) CloudwatchLogDestination {
+	// the destination is regional, but what it points at doesn't have to be
+	destination := NewCloudwatchLogDestination(ctx.Scope, jsii.String(ctx.Id+"_destination"), &CloudwatchLogDestinationConfig{
+		Provider:  ctx.Provider,
+		Name:      jsii.String(*cfg.Name + "-log-archive"),
+		RoleArn:   cfg.role,
+		TargetArn: cfg.stream,
+	})
+
+	NewCloudwatchLogDestinationPolicy(ctx.Scope, jsii.String(ctx.Id+"_destination_policy"), &CloudwatchLogDestinationPolicyConfig{
+		Provider:        ctx.Provider,
+		DestinationName: destination.Name(),
+		AccessPolicy: NewDataAwsIamPolicyDocument(ctx.Scope, jsii.String(ctx.Id+"_destination_policy_doc"), &DataAwsIamPolicyDocumentConfig{
+			Statement: []DataAwsIamPolicyDocumentStatement{
+				{
+					Effect:    jsii.String("Allow"),
+					Actions:   jsii.Strings("logs:PutSubscriptionFilter"),
+					Resources: jsii.Strings(*destination.Arn()),
+					Principals: []DataAwsIamPolicyDocumentStatementPrincipals{
+						{
+							Type:        jsii.String("AWS"),
+							Identifiers: jsii.Strings(*cfg.AccountId),
+						},
+					},
+				},
+			},
+		}).Json(),
+	})
+
+	return destination
+}
+
+func (archive logArchive) DestinationArns() map[string]*string {
+	result := map[string]*string{}
+	for region, destination := range archive.Destinations {
+		result[region] = destination.Arn()
+	}
+	return result
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchloggroup"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsdynamodbtable"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawss3object"
@@ -21,6 +22,7 @@ import (
 
 type matchMakers struct {
 	UnrankedSolo queue
+	Healthcheck  queue
 }
 
 type queue struct {
@@ -31,8 +33,10 @@ type queue struct {
 }
 
 type queueInstance struct {
-	Function LambdaFunction
-	Table    DataAwsDynamodbTable
+	Function      LambdaFunction
+	Table         DataAwsDynamodbTable
+	IteratorAlarm CloudwatchMetricAlarm
+	LogGroup      CloudwatchLogGroup
 }
 
 type MatchMakeConfig struct {
@@ -42,10 +46,17 @@ type MatchMakeConfig struct {
 	Code           common.ObjectConfig
 	KmsArns        common.MultiRegionId
 	LambdaIam      common.LambdaIamConfig
+	Tracing        common.LambdaTracingConfig
+	Logs           common.LogGroupConfig
+	IteratorAge    common.IteratorAgeAlarmConfig
 	MatchTables    map[string]common.ArnIdPair
 	LockTables     map[string]common.ArnIdPair
 	LockRegions    []string
-	AlarmIds       map[string]common.ArnIdPair
+	// processors stop when any of these alarms are active in their region
+	AlarmIds []map[string]common.ArnIdPair
+	// applies to every queue table and replica
+	PointInTimeRecovery bool
+	DeletionProtection  bool
 }
 
 type queueConfig struct {
@@ -60,20 +71,25 @@ type instanceConfig struct {
 	table  *string
 }
 
-const queue_sort = "queue_sort"
-
 func (cfg MatchMakeConfig) New(ctx common.TfContext) matchMakers {
+	common.TagComponent(ctx, common.COMPONENT_MATCH_MAKE)
+
 	// init queue info
 	result := matchMakers{
 		UnrankedSolo: queue{
 			name:         common.QUEUE_UNRANKED_SOLO,
 			codeLocation: "rust/target/lambda/process-queue-unranked-solo/bootstrap.zip",
 		},
+		Healthcheck: queue{
+			name:         common.QUEUE_HEALTHCHECK,
+			codeLocation: "rust/target/lambda/process-queue-healthcheck/bootstrap.zip",
+		},
 	}
 
 	// loop to create all queues
 	queues := []*queue{
 		&result.UnrankedSolo,
+		&result.Healthcheck,
 	}
 
 	for _, queue := range queues {
@@ -94,9 +110,10 @@ func (cfg queueConfig) new(ctx common.TfContext) queue {
 	tableReplicas := []DynamodbTableReplica{}
 	for region, arn := range cfg.KmsArns.Replicas {
 		tableReplicas = append(tableReplicas, DynamodbTableReplica{
-			RegionName:    jsii.String(region),
-			KmsKeyArn:     arn,
-			PropagateTags: jsii.Bool(true),
+			RegionName:          jsii.String(region),
+			KmsKeyArn:           arn,
+			PropagateTags:       jsii.Bool(true),
+			PointInTimeRecovery: jsii.Bool(cfg.PointInTimeRecovery),
 		})
 	}
 
@@ -109,17 +126,21 @@ func (cfg queueConfig) new(ctx common.TfContext) queue {
 		StreamEnabled:  jsii.Bool(true),
 		StreamViewType: jsii.String("NEW_AND_OLD_IMAGES"),
 		Replica:        &tableReplicas,
+		Lifecycle:      common.Protect(cfg.DeletionProtection),
 		ServerSideEncryption: &DynamodbTableServerSideEncryption{
 			Enabled:   jsii.Bool(true),
 			KmsKeyArn: cfg.KmsArns.Primary,
 		},
+		PointInTimeRecovery: &DynamodbTablePointInTimeRecovery{
+			Enabled: jsii.Bool(cfg.PointInTimeRecovery),
+		},
 		Ttl: &DynamodbTableTtl{
 			Enabled:       jsii.Bool(true),
 			AttributeName: jsii.String("ttl"),
 		},
 		GlobalSecondaryIndex: &[]DynamodbTableGlobalSecondaryIndex{
 			{
-				Name:           jsii.String(queue_sort),
+				Name:           jsii.String(common.QUEUE_SORT_INDEX),
 				HashKey:        jsii.String("queue"),
 				RangeKey:       jsii.String("join_time"),
 				ProjectionType: jsii.String("ALL"),
@@ -162,7 +183,7 @@ func (cfg queueConfig) new(ctx common.TfContext) queue {
 	for _, instance := range instances {
 		tableArns = append(tableArns, instance.Table.Arn())
 		streamArns = append(streamArns, instance.Table.StreamArn())
-		indexArns = append(indexArns, jsii.String(*instance.Table.Arn()+"/index/"+queue_sort))
+		indexArns = append(indexArns, jsii.String(*instance.Table.Arn()+"/index/"+common.QUEUE_SORT_INDEX))
 	}
 
 	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_lambda_role_queue_policy"), &IamRolePolicyConfig{
@@ -195,10 +216,11 @@ func (cfg queueConfig) new(ctx common.TfContext) queue {
 
 func (cfg queueConfig) lambdaRole(ctx common.TfContext) IamRole {
 	lambdaRole := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
-		Provider:         ctx.Provider,
-		Name:             jsii.String(*cfg.Name + "-lambda"),
-		Path:             cfg.LambdaIam.Path,
-		AssumeRolePolicy: cfg.LambdaIam.AssumeRole,
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.Name + "-lambda"),
+		Path:                cfg.LambdaIam.Path,
+		AssumeRolePolicy:    cfg.LambdaIam.AssumeRole,
+		PermissionsBoundary: cfg.LambdaIam.PermissionsBoundary,
 	})
 
 	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_policy_exec"), &IamRolePolicyAttachmentConfig{
@@ -215,7 +237,10 @@ func (cfg queueConfig) lambdaRole(ctx common.TfContext) IamRole {
 
 	lockTables := common.ArnsToList(cfg.LockTables)
 	matchTables := common.ArnsToList(cfg.MatchTables)
-	alarmArns := common.ArnsToList(cfg.AlarmIds)
+	alarmArns := []*string{}
+	for _, alarms := range cfg.AlarmIds {
+		alarmArns = append(alarmArns, common.ArnsToList(alarms)...)
+	}
 
 	NewIamRolePolicy(ctx.Scope, jsii.String(ctx.Id+"_tables_policy"), &IamRolePolicyConfig{
 		Provider: ctx.Provider,
@@ -242,15 +267,25 @@ func (cfg queueConfig) lambdaRole(ctx common.TfContext) IamRole {
 		}).Json(),
 	})
 
+	common.FailureQueueConfig{}.AllowSend(common.SimpleContext(ctx.Scope, ctx.Id+"_failures", ctx.Provider), lambdaRole.Name(), cfg.Name)
+
+	if cfg.Tracing.Enabled {
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_xray"), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      lambdaRole.Name(),
+			PolicyArn: cfg.Tracing.Policy,
+		})
+	}
+
 	return lambdaRole
 }
 
 func (cfg instanceConfig) new(ctx common.TfContext) queueInstance {
-	logGroup := NewCloudwatchLogGroup(ctx.Scope, jsii.String(ctx.Id+"_logs"), &CloudwatchLogGroupConfig{
-		Provider:        ctx.Provider,
-		Name:            jsii.String("/aws/lambda/" + *cfg.Name),
-		RetentionInDays: jsii.Number(7),
-	})
+	logGroup := cfg.Logs.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_logs", ctx.Provider),
+		jsii.String("/aws/lambda/"+*cfg.Name),
+		cfg.region,
+	)
 
 	table := NewDataAwsDynamodbTable(ctx.Scope, jsii.String(ctx.Id+"_table"), &DataAwsDynamodbTableConfig{
 		Provider: ctx.Provider,
@@ -264,13 +299,13 @@ func (cfg instanceConfig) new(ctx common.TfContext) queueInstance {
 	})
 
 	alarmNames := []string{}
-	for _, alarm := range cfg.AlarmIds {
-		alarmNames = append(alarmNames, *alarm.Id)
+	for _, alarms := range cfg.AlarmIds {
+		alarmNames = append(alarmNames, *alarms[cfg.region].Id)
 	}
 
 	lambdaEnv := map[string]*string{
 		"QUEUE_TABLE":  table.Id(),
-		"QUEUE_INDEX":  jsii.String(queue_sort),
+		"QUEUE_INDEX":  jsii.String(common.QUEUE_SORT_INDEX),
 		"MATCH_TABLE":  cfg.MatchTables[cfg.region].Id,
 		"LOCK_TABLE":   cfg.LockTables[cfg.region].Id,
 		"LOCK_REGIONS": jsii.String(strings.Join(cfg.LockRegions, ",")),
@@ -295,16 +330,26 @@ func (cfg instanceConfig) new(ctx common.TfContext) queueInstance {
 		MemorySize:      jsii.Number(128),
 		Timeout:         jsii.Number(15),
 		DependsOn:       &lambdaDependsOn,
+		TracingConfig: &LambdaFunctionTracingConfig{
+			Mode: cfg.Tracing.Mode(),
+		},
 		Environment: &LambdaFunctionEnvironment{
 			Variables: &lambdaEnv,
 		},
 	})
+	common.Exempt(lambda, common.CHECK_DEAD_LETTERS, "only invoked by its stream mapping, whose failed batches go to the failure queue")
 
 	filter := map[string]any{
 		"eventName": []string{"MODIFY", "INSERT"},
 	}
 	filterBytes, _ := json.Marshal(filter)
 
+	failures := common.FailureQueueConfig{Topics: cfg.IteratorAge.Topics}.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_failures", ctx.Provider),
+		cfg.Name,
+		cfg.region,
+	)
+
 	NewLambdaEventSourceMapping(ctx.Scope, jsii.String(ctx.Id+"_stream"), &LambdaEventSourceMappingConfig{
 		Provider:                       ctx.Provider,
 		FunctionName:                   lambda.FunctionName(),
@@ -313,6 +358,11 @@ func (cfg instanceConfig) new(ctx common.TfContext) queueInstance {
 		StartingPosition:               jsii.String("LATEST"),
 		MaximumBatchingWindowInSeconds: jsii.Number(2),
 		MaximumRetryAttempts:           jsii.Number(6),
+		DestinationConfig: &LambdaEventSourceMappingDestinationConfig{
+			OnFailure: &LambdaEventSourceMappingDestinationConfigOnFailure{
+				DestinationArn: failures.Arn(),
+			},
+		},
 		FilterCriteria: &LambdaEventSourceMappingFilterCriteria{
 			Filter: &[]LambdaEventSourceMappingFilterCriteriaFilter{
 				{
@@ -322,7 +372,14 @@ func (cfg instanceConfig) new(ctx common.TfContext) queueInstance {
 		},
 	})
 
-	return queueInstance{lambda, table}
+	iteratorAlarm := cfg.IteratorAge.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_iterator_alarm", ctx.Provider),
+		cfg.Name,
+		lambda.FunctionName(),
+		cfg.region,
+	)
+
+	return queueInstance{lambda, table, iteratorAlarm, logGroup}
 }
 
 func (queue queue) Name() string {
@@ -334,3 +391,58 @@ func (queue queue) Tables() map[string]common.ArnIdPair {
 		return common.TableToIdPair(instance.Table)
 	})
 }
+
+func (queue queue) FunctionIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(queue.Regions, func(instance queueInstance) common.ArnIdPair {
+		return common.FunctionToIdPair(instance.Function)
+	})
+}
+
+func (queue queue) IteratorAgeAlarmIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(queue.Regions, func(instance queueInstance) common.ArnIdPair {
+		return common.ArnIdPair{Arn: instance.IteratorAlarm.Arn(), Id: instance.IteratorAlarm.AlarmName()}
+	})
+}
+
+func (queue queue) LogGroupIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(queue.Regions, func(instance queueInstance) common.ArnIdPair {
+		return common.ArnIdPair{Arn: instance.LogGroup.Arn(), Id: instance.LogGroup.Name()}
+	})
+}
+
+func (makers matchMakers) toList() []queue {
+	return []queue{makers.UnrankedSolo, makers.Healthcheck}
+}
+
+// queues real players use, the canary's matches would skew business metrics
+func (makers matchMakers) playerQueues() []queue {
+	return []queue{makers.UnrankedSolo}
+}
+
+func (makers matchMakers) FunctionIds() (result []map[string]common.ArnIdPair) {
+	for _, queue := range makers.toList() {
+		result = append(result, queue.FunctionIds())
+	}
+	return
+}
+
+func (makers matchMakers) IteratorAgeAlarmIds() (result []map[string]common.ArnIdPair) {
+	for _, queue := range makers.toList() {
+		result = append(result, queue.IteratorAgeAlarmIds())
+	}
+	return
+}
+
+func (makers matchMakers) TableIds() (result []map[string]common.ArnIdPair) {
+	for _, queue := range makers.toList() {
+		result = append(result, queue.Tables())
+	}
+	return
+}
+
+func (makers matchMakers) PlayerLogGroupIds() (result []map[string]common.ArnIdPair) {
+	for _, queue := range makers.playerQueues() {
+		result = append(result, queue.LogGroupIds())
+	}
+	return
+}
@@ -6,7 +6,7 @@ import (
 
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
 	"github.com/aws/jsii-runtime-go"
-	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchloggroup"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsdynamodbtable"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawsiampolicydocument"
 	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/dataawss3object"
@@ -25,8 +25,9 @@ type matchPublish struct {
 }
 
 type matchPublishInstance struct {
-	Function LambdaFunction
-	Table    DataAwsDynamodbTable
+	Function      LambdaFunction
+	Table         DataAwsDynamodbTable
+	IteratorAlarm CloudwatchMetricAlarm
 }
 
 type MatchPublishConfig struct {
@@ -36,7 +37,13 @@ type MatchPublishConfig struct {
 	Code          common.ObjectConfig
 	KmsArns       common.MultiRegionId
 	LambdaIam     common.LambdaIamConfig
+	Tracing       common.LambdaTracingConfig
+	Logs          common.LogGroupConfig
+	IteratorAge   common.IteratorAgeAlarmConfig
 	ApiUrl        string
+	// applies to every replica
+	PointInTimeRecovery bool
+	DeletionProtection  bool
 }
 
 type instanceConfig struct {
@@ -47,13 +54,16 @@ type instanceConfig struct {
 }
 
 func (cfg MatchPublishConfig) New(ctx common.TfContext) matchPublish {
+	common.TagComponent(ctx, common.COMPONENT_MATCH_PUBLISH)
+
 	// create tables w/ replicas in each region
 	tableReplicas := []DynamodbTableReplica{}
 	for region, arn := range cfg.KmsArns.Replicas {
 		tableReplicas = append(tableReplicas, DynamodbTableReplica{
-			RegionName:    jsii.String(region),
-			KmsKeyArn:     arn,
-			PropagateTags: jsii.Bool(true),
+			RegionName:          jsii.String(region),
+			KmsKeyArn:           arn,
+			PropagateTags:       jsii.Bool(true),
+			PointInTimeRecovery: jsii.Bool(cfg.PointInTimeRecovery),
 		})
 	}
 
@@ -66,10 +76,14 @@ func (cfg MatchPublishConfig) New(ctx common.TfContext) matchPublish {
 		StreamEnabled:  jsii.Bool(true),
 		StreamViewType: jsii.String("NEW_AND_OLD_IMAGES"),
 		Replica:        &tableReplicas,
+		Lifecycle:      common.Protect(cfg.DeletionProtection),
 		ServerSideEncryption: &DynamodbTableServerSideEncryption{
 			Enabled:   jsii.Bool(true),
 			KmsKeyArn: cfg.KmsArns.Primary,
 		},
+		PointInTimeRecovery: &DynamodbTablePointInTimeRecovery{
+			Enabled: jsii.Bool(cfg.PointInTimeRecovery),
+		},
 		Ttl: &DynamodbTableTtl{
 			Enabled:       jsii.Bool(true),
 			AttributeName: jsii.String("ttl"),
@@ -149,10 +163,11 @@ func (app matchPublish) AddApiPerms(ctx common.TfContext, arns []*string) {
 
 func (cfg MatchPublishConfig) lambdaRole(ctx common.TfContext) IamRole {
 	lambdaRole := NewIamRole(ctx.Scope, jsii.String(ctx.Id), &IamRoleConfig{
-		Provider:         ctx.Provider,
-		Name:             jsii.String(*cfg.Name + "-lambda"),
-		Path:             cfg.LambdaIam.Path,
-		AssumeRolePolicy: cfg.LambdaIam.AssumeRole,
+		Provider:            ctx.Provider,
+		Name:                jsii.String(*cfg.Name + "-lambda"),
+		Path:                cfg.LambdaIam.Path,
+		AssumeRolePolicy:    cfg.LambdaIam.AssumeRole,
+		PermissionsBoundary: cfg.LambdaIam.PermissionsBoundary,
 	})
 
 	NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_policy_exec"), &IamRolePolicyAttachmentConfig{
@@ -167,15 +182,25 @@ func (cfg MatchPublishConfig) lambdaRole(ctx common.TfContext) IamRole {
 		PolicyArn: cfg.KmsReadPolicy,
 	})
 
+	common.FailureQueueConfig{}.AllowSend(common.SimpleContext(ctx.Scope, ctx.Id+"_failures", ctx.Provider), lambdaRole.Name(), cfg.Name)
+
+	if cfg.Tracing.Enabled {
+		NewIamRolePolicyAttachment(ctx.Scope, jsii.String(ctx.Id+"_xray"), &IamRolePolicyAttachmentConfig{
+			Provider:  ctx.Provider,
+			Role:      lambdaRole.Name(),
+			PolicyArn: cfg.Tracing.Policy,
+		})
+	}
+
 	return lambdaRole
 }
 
 func (cfg instanceConfig) new(ctx common.TfContext) matchPublishInstance {
-	logGroup := NewCloudwatchLogGroup(ctx.Scope, jsii.String(ctx.Id+"_logs"), &CloudwatchLogGroupConfig{
-		Provider:        ctx.Provider,
-		Name:            jsii.String("/aws/lambda/" + *cfg.Name),
-		RetentionInDays: jsii.Number(7),
-	})
+	logGroup := cfg.Logs.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_logs", ctx.Provider),
+		jsii.String("/aws/lambda/"+*cfg.Name),
+		cfg.region,
+	)
 
 	table := NewDataAwsDynamodbTable(ctx.Scope, jsii.String(ctx.Id+"_table"), &DataAwsDynamodbTableConfig{
 		Provider: ctx.Provider,
@@ -210,10 +235,14 @@ func (cfg instanceConfig) new(ctx common.TfContext) matchPublishInstance {
 		MemorySize:      jsii.Number(128),
 		Timeout:         jsii.Number(15),
 		DependsOn:       &lambdaDependsOn,
+		TracingConfig: &LambdaFunctionTracingConfig{
+			Mode: cfg.Tracing.Mode(),
+		},
 		Environment: &LambdaFunctionEnvironment{
 			Variables: &lambdaEnv,
 		},
 	})
+	common.Exempt(lambda, common.CHECK_DEAD_LETTERS, "only invoked by its stream mapping, whose failed batches go to the failure queue")
 
 	filter := map[string]any{
 		// only inserts/updates (not sure what an update is here actually?)
@@ -235,6 +264,12 @@ func (cfg instanceConfig) new(ctx common.TfContext) matchPublishInstance {
 	}
 	filterBytes, _ := json.Marshal(filter)
 
+	failures := common.FailureQueueConfig{Topics: cfg.IteratorAge.Topics}.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_failures", ctx.Provider),
+		cfg.Name,
+		cfg.region,
+	)
+
 	NewLambdaEventSourceMapping(ctx.Scope, jsii.String(ctx.Id+"_stream"), &LambdaEventSourceMappingConfig{
 		Provider:                       ctx.Provider,
 		FunctionName:                   lambda.FunctionName(),
@@ -243,6 +278,11 @@ func (cfg instanceConfig) new(ctx common.TfContext) matchPublishInstance {
 		StartingPosition:               jsii.String("LATEST"),
 		MaximumBatchingWindowInSeconds: jsii.Number(2),
 		MaximumRetryAttempts:           jsii.Number(6),
+		DestinationConfig: &LambdaEventSourceMappingDestinationConfig{
+			OnFailure: &LambdaEventSourceMappingDestinationConfigOnFailure{
+				DestinationArn: failures.Arn(),
+			},
+		},
 		FilterCriteria: &LambdaEventSourceMappingFilterCriteria{
 			Filter: &[]LambdaEventSourceMappingFilterCriteriaFilter{
 				{
@@ -252,7 +292,14 @@ func (cfg instanceConfig) new(ctx common.TfContext) matchPublishInstance {
 		},
 	})
 
-	return matchPublishInstance{lambda, table}
+	iteratorAlarm := cfg.IteratorAge.New(
+		common.SimpleContext(ctx.Scope, ctx.Id+"_iterator_alarm", ctx.Provider),
+		cfg.Name,
+		lambda.FunctionName(),
+		cfg.region,
+	)
+
+	return matchPublishInstance{lambda, table, iteratorAlarm}
 }
 
 func (app matchPublish) TableIds() map[string]common.ArnIdPair {
@@ -260,3 +307,15 @@ func (app matchPublish) TableIds() map[string]common.ArnIdPair {
 		return common.TableToIdPair(instance.Table)
 	})
 }
+
+func (app matchPublish) FunctionIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance matchPublishInstance) common.ArnIdPair {
+		return common.FunctionToIdPair(instance.Function)
+	})
+}
+
+func (app matchPublish) IteratorAgeAlarmIds() map[string]common.ArnIdPair {
+	return common.TransformMapValues(app.Regions, func(instance matchPublishInstance) common.ArnIdPair {
+		return common.ArnIdPair{Arn: instance.IteratorAlarm.Arn(), Id: instance.IteratorAlarm.AlarmName()}
+	})
+}
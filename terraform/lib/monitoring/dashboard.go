@@ -0,0 +1,218 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchdashboard"
+)
+
+type dashboards struct {
+	Regions map[string]CloudwatchDashboard
+	Global  CloudwatchDashboard
+}
+
+// everything is keyed by region, lists are one entry per resource
+type DashboardConfig struct {
+	Providers          common.Providers
+	Name               *string
+	Apis               map[string]common.ArnIdPair
+	RegionHealthAlarms map[string]common.ArnIdPair
+	Functions          []map[string]common.ArnIdPair
+	StreamFunctions    []map[string]common.ArnIdPair
+	Tables             []map[string]common.ArnIdPair
+}
+
+type dashboardInstanceConfig struct {
+	DashboardConfig
+	region string
+}
+
+type dashboardBody struct {
+	Widgets []dashboardWidget `json:"widgets"`
+}
+
+// no x/y, cloudwatch lays widgets out left to right in order
+type dashboardWidget struct {
+	Type       string      `json:"type"`
+	Width      int         `json:"width"`
+	Height     int         `json:"height"`
+	Properties interface{} `json:"properties"`
+}
+
+type metricProperties struct {
+	Title   string          `json:"title"`
+	Region  string          `json:"region"`
+	View    string          `json:"view"`
+	Stat    string          `json:"stat"`
+	Period  int             `json:"period"`
+	Metrics [][]interface{} `json:"metrics"`
+}
+
+type alarmProperties struct {
+	Title  string    `json:"title"`
+	Alarms []*string `json:"alarms"`
+}
+
+type metricOptions struct {
+	Label  *string `json:"label,omitempty"`
+	Region string  `json:"region,omitempty"`
+	Stat   string  `json:"stat,omitempty"`
+}
+
+func metric(namespace, name, dimension string, value *string, options metricOptions) []interface{} {
+	return []interface{}{namespace, name, dimension, value, options}
+}
+
+func metricWidget(title, region, stat string, metrics [][]interface{}) dashboardWidget {
+	return dashboardWidget{
+		Type:   "metric",
+		Width:  8,
+		Height: 6,
+		Properties: metricProperties{
+			Title:   title,
+			Region:  region,
+			View:    "timeSeries",
+			Stat:    stat,
+			Period:  60,
+			Metrics: metrics,
+		},
+	}
+}
+
+func (cfg DashboardConfig) New(ctx common.TfContext) dashboards {
+	common.TagComponent(ctx, common.COMPONENT_MONITORING)
+
+	// create a dashboard for each region
+	instances := map[string]CloudwatchDashboard{}
+	for region, provider := range cfg.Providers {
+		instances[region] = dashboardInstanceConfig{
+			DashboardConfig: cfg,
+			region:          region,
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider))
+	}
+
+	// dashboards are global, so the side by side view only needs to exist once
+	global := NewCloudwatchDashboard(ctx.Scope, jsii.String(ctx.Id+"_global"), &CloudwatchDashboardConfig{
+		Provider:      ctx.Provider,
+		DashboardName: jsii.String(*cfg.Name + "-global"),
+		DashboardBody: toBody(cfg.globalWidgets()),
+	})
+
+	return dashboards{instances, global}
+}
+
+func (cfg dashboardInstanceConfig) new(ctx common.TfContext) CloudwatchDashboard {
+	return NewCloudwatchDashboard(ctx.Scope, jsii.String(ctx.Id), &CloudwatchDashboardConfig{
+		Provider:      ctx.Provider,
+		DashboardName: jsii.String(*cfg.Name + "-" + cfg.region),
+		DashboardBody: toBody(cfg.widgets()),
+	})
+}
+
+func (cfg dashboardInstanceConfig) widgets() []dashboardWidget {
+	apiId := cfg.Apis[cfg.region].Id
+	functions := func(name string) (metrics [][]interface{}) {
+		for _, functionIds := range cfg.Functions {
+			functionName := functionIds[cfg.region].Id
+			metrics = append(metrics, metric("AWS/Lambda", name, "FunctionName", functionName, metricOptions{Label: functionName}))
+		}
+		return
+	}
+	tables := func(names ...string) (metrics [][]interface{}) {
+		for _, tableIds := range cfg.Tables {
+			tableName := tableIds[cfg.region].Id
+			for _, name := range names {
+				metrics = append(metrics, metric("AWS/DynamoDB", name, "TableName", tableName, metricOptions{}))
+			}
+		}
+		return
+	}
+	streams := [][]interface{}{}
+	for _, functionIds := range cfg.StreamFunctions {
+		functionName := functionIds[cfg.region].Id
+		streams = append(streams, metric("AWS/Lambda", "IteratorAge", "FunctionName", functionName, metricOptions{Label: functionName}))
+	}
+
+	return []dashboardWidget{
+		{
+			Type:   "alarm",
+			Width:  24,
+			Height: 2,
+			Properties: alarmProperties{
+				Title:  "Region health",
+				Alarms: []*string{cfg.RegionHealthAlarms[cfg.region].Arn},
+			},
+		},
+		// appsync only reports request counts per account, so latency samples stand in for them
+		metricWidget("API requests", cfg.region, "SampleCount", [][]interface{}{
+			metric("AWS/AppSync", "Latency", "GraphQLAPIId", apiId, metricOptions{Label: jsii.String("Requests")}),
+		}),
+		metricWidget("API errors", cfg.region, "Sum", [][]interface{}{
+			metric("AWS/AppSync", "4XXError", "GraphQLAPIId", apiId, metricOptions{}),
+			metric("AWS/AppSync", "5XXError", "GraphQLAPIId", apiId, metricOptions{}),
+		}),
+		metricWidget("API latency", cfg.region, "Average", [][]interface{}{
+			metric("AWS/AppSync", "Latency", "GraphQLAPIId", apiId, metricOptions{Label: jsii.String("Average")}),
+			metric("AWS/AppSync", "Latency", "GraphQLAPIId", apiId, metricOptions{Label: jsii.String("p99"), Stat: "p99"}),
+		}),
+		metricWidget("Lambda invocations", cfg.region, "Sum", functions("Invocations")),
+		metricWidget("Lambda duration", cfg.region, "Average", functions("Duration")),
+		metricWidget("Lambda errors", cfg.region, "Sum", functions("Errors")),
+		metricWidget("DynamoDB consumed capacity", cfg.region, "Sum", tables("ConsumedReadCapacityUnits", "ConsumedWriteCapacityUnits")),
+		metricWidget("DynamoDB throttles", cfg.region, "Sum", tables("ReadThrottleEvents", "WriteThrottleEvents")),
+		metricWidget("Stream iterator age", cfg.region, "Maximum", streams),
+	}
+}
+
+func (cfg DashboardConfig) globalWidgets() []dashboardWidget {
+	regions := []string{}
+	for region := range cfg.Providers {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	byRegion := func(namespace, name, dimension string, ids map[string]common.ArnIdPair, stat string) (metrics [][]interface{}) {
+		for _, region := range regions {
+			metrics = append(metrics, metric(namespace, name, dimension, ids[region].Id, metricOptions{
+				Label:  jsii.String(region),
+				Region: region,
+				Stat:   stat,
+			}))
+		}
+		return
+	}
+
+	alarms := []*string{}
+	for _, region := range regions {
+		alarms = append(alarms, cfg.RegionHealthAlarms[region].Arn)
+	}
+
+	widgets := []dashboardWidget{
+		{
+			Type:   "alarm",
+			Width:  24,
+			Height: 2,
+			Properties: alarmProperties{
+				Title:  "Region health",
+				Alarms: alarms,
+			},
+		},
+		metricWidget("API requests", regions[0], "SampleCount", byRegion("AWS/AppSync", "Latency", "GraphQLAPIId", cfg.Apis, "SampleCount")),
+		metricWidget("API server errors", regions[0], "Sum", byRegion("AWS/AppSync", "5XXError", "GraphQLAPIId", cfg.Apis, "Sum")),
+		metricWidget("API latency", regions[0], "Average", byRegion("AWS/AppSync", "Latency", "GraphQLAPIId", cfg.Apis, "Average")),
+	}
+
+	for _, functionIds := range cfg.StreamFunctions {
+		widgets = append(widgets, metricWidget("Iterator age - "+*functionIds[regions[0]].Id, regions[0], "Maximum", byRegion("AWS/Lambda", "IteratorAge", "FunctionName", functionIds, "Maximum")))
+	}
+
+	return widgets
+}
+
+func toBody(widgets []dashboardWidget) *string {
+	body, _ := json.Marshal(dashboardBody{widgets})
+	return jsii.String(string(body))
+}
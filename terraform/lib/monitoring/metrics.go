@@ -0,0 +1,136 @@
+package monitoring
+
+import (
+	"fmt"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchlogmetricfilter"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
+)
+
+type businessMetrics struct {
+	Regions map[string]businessMetricsInstance
+}
+
+type businessMetricsInstance struct {
+	Filters        []CloudwatchLogMetricFilter
+	NoMatchesAlarm CloudwatchMetricAlarm
+}
+
+type BusinessMetricsConfig struct {
+	Providers common.Providers
+	Name      *string
+	Namespace *string
+	// keyed by region
+	ApiLogGroups map[string]common.ArnIdPair
+	// one entry per queue processor, keyed by region
+	QueueLogGroups []map[string]common.ArnIdPair
+}
+
+type businessMetricsInstanceConfig struct {
+	BusinessMetricsConfig
+	region string
+}
+
+type metricFilter struct {
+	metric  string
+	pattern string
+	value   string
+	unit    string
+}
+
+// queue processors log these as {"metric": <name>, "value": <value>}
+var queueMetrics = []metricFilter{
+	{metric: "MatchesMade", unit: "Count"},
+	{metric: "QueueWaitTime", unit: "Seconds"},
+	{metric: "QueueDepth", unit: "Count"},
+	{metric: "LockContention", unit: "Count"},
+}
+
+// logged by check-ip-cache.resp.vm at error level, so any field log level but NONE picks them up
+var apiMetrics = []metricFilter{
+	{metric: "IpCacheHits", pattern: `"IpCacheCheck" "Hit"`, value: "1", unit: "Count"},
+	{metric: "IpCacheMisses", pattern: `"IpCacheCheck" "Miss"`, value: "1", unit: "Count"},
+}
+
+func (cfg BusinessMetricsConfig) New(ctx common.TfContext) businessMetrics {
+	common.TagComponent(ctx, common.COMPONENT_MONITORING)
+
+	// create an instance of the service in each region
+	instances := map[string]businessMetricsInstance{}
+	for region, provider := range cfg.Providers {
+		instances[region] = businessMetricsInstanceConfig{
+			BusinessMetricsConfig: cfg,
+			region:                region,
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider))
+	}
+
+	return businessMetrics{instances}
+}
+
+func (cfg businessMetricsInstanceConfig) new(ctx common.TfContext) businessMetricsInstance {
+	filters := []CloudwatchLogMetricFilter{}
+	newFilter := func(id string, logGroup *string, filter metricFilter) {
+		filters = append(filters, NewCloudwatchLogMetricFilter(ctx.Scope, jsii.String(id), &CloudwatchLogMetricFilterConfig{
+			Provider:     ctx.Provider,
+			Name:         jsii.String(*cfg.Name + "-" + filter.metric),
+			LogGroupName: logGroup,
+			Pattern:      jsii.String(filter.pattern),
+			MetricTransformation: &CloudwatchLogMetricFilterMetricTransformation{
+				Name:      jsii.String(filter.metric),
+				Namespace: cfg.Namespace,
+				Value:     jsii.String(filter.value),
+				Unit:      jsii.String(filter.unit),
+			},
+		}))
+	}
+
+	for i, logGroups := range cfg.QueueLogGroups {
+		for _, filter := range queueMetrics {
+			filter.pattern = fmt.Sprintf(`{ $.metric = "%s" }`, filter.metric)
+			filter.value = "$.value"
+			newFilter(fmt.Sprintf("%s_queue_%d_%s", ctx.Id, i, filter.metric), logGroups[cfg.region].Id, filter)
+		}
+	}
+
+	for _, filter := range apiMetrics {
+		newFilter(ctx.Id+"_api_"+filter.metric, cfg.ApiLogGroups[cfg.region].Id, filter)
+	}
+
+	metric := func(id string, name string, stat string) CloudwatchMetricAlarmMetricQuery {
+		return CloudwatchMetricAlarmMetricQuery{
+			Id: jsii.String(id),
+			Metric: &CloudwatchMetricAlarmMetricQueryMetric{
+				Namespace:  cfg.Namespace,
+				MetricName: jsii.String(name),
+				Stat:       jsii.String(stat),
+				Period:     jsii.Number(3600),
+			},
+		}
+	}
+
+	// a single player can't be matched, so only a queue of 2+ should be producing matches
+	noMatchesAlarm := NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(ctx.Id+"_no_matches_alarm"), &CloudwatchMetricAlarmConfig{
+		Provider:           ctx.Provider,
+		AlarmName:          jsii.String(*cfg.Name + "-no-matches"),
+		AlarmDescription:   jsii.String(fmt.Sprintf("[%s/matches/none-made] - no matches were made in %s over the last hour while players were queued", *cfg.Name, cfg.region)),
+		ComparisonOperator: jsii.String("GreaterThanOrEqualToThreshold"),
+		Threshold:          jsii.Number(1),
+		EvaluationPeriods:  jsii.Number(1),
+		DatapointsToAlarm:  jsii.Number(1),
+		TreatMissingData:   jsii.String("notBreaching"),
+		MetricQuery: []CloudwatchMetricAlarmMetricQuery{
+			metric("matches", "MatchesMade", "Sum"),
+			metric("depth", "QueueDepth", "Maximum"),
+			{
+				Id:         jsii.String("stalled"),
+				Label:      jsii.String("queued without matches"),
+				Expression: jsii.String("IF(FILL(depth, 0) > 1 AND FILL(matches, 0) == 0, 1, 0)"),
+				ReturnData: jsii.Bool(true),
+			},
+		},
+	})
+
+	return businessMetricsInstance{Filters: filters, NoMatchesAlarm: noMatchesAlarm}
+}
@@ -0,0 +1,113 @@
+package monitoring
+
+import (
+	"fmt"
+
+	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_common"
+	"github.com/aws/jsii-runtime-go"
+	. "github.com/cdktf/cdktf-provider-aws-go/aws/v10/cloudwatchmetricalarm"
+)
+
+type monitoring struct {
+	Regions map[string]monitoringInstance
+}
+
+type monitoringInstance struct {
+	// errors + throttles for each function, in the order the functions were given
+	FunctionAlarms []CloudwatchMetricAlarm
+	// same, for the health functions
+	HealthAlarms []CloudwatchMetricAlarm
+}
+
+type MonitoringConfig struct {
+	Providers common.Providers
+	Name      *string
+	// every function must exist in every region
+	Functions []map[string]common.ArnIdPair
+	// functions whose alarms also count towards region health
+	HealthFunctions []map[string]common.ArnIdPair
+	// sns topics notified on alarm/ok, keyed by region
+	Topics map[string]*string
+}
+
+type instanceConfig struct {
+	MonitoringConfig
+	region string
+}
+
+type functionMetric struct {
+	name      string
+	metric    string
+	threshold float64
+	text      string
+}
+
+var functionMetrics = []functionMetric{
+	{name: "errors", metric: "Errors", threshold: 1, text: "is failing invocations"},
+	{name: "throttles", metric: "Throttles", threshold: 1, text: "is being throttled"},
+}
+
+func (cfg MonitoringConfig) New(ctx common.TfContext) monitoring {
+	common.TagComponent(ctx, common.COMPONENT_MONITORING)
+
+	// create an instance of the service in each region
+	instances := map[string]monitoringInstance{}
+	for region, provider := range cfg.Providers {
+		instances[region] = instanceConfig{
+			MonitoringConfig: cfg,
+			region:           region,
+		}.new(common.SimpleContext(ctx.Scope, ctx.Id+"_"+region, provider))
+	}
+
+	return monitoring{instances}
+}
+
+func (cfg instanceConfig) new(ctx common.TfContext) monitoringInstance {
+	actions := []*string{}
+	if topic, ok := cfg.Topics[cfg.region]; ok {
+		actions = append(actions, topic)
+	}
+
+	// health functions go last so the ids match the order they were always created in
+	alarms := []CloudwatchMetricAlarm{}
+	for i, functions := range append(cfg.Functions, cfg.HealthFunctions...) {
+		functionName := functions[cfg.region].Id
+		for _, metric := range functionMetrics {
+			alarms = append(alarms, NewCloudwatchMetricAlarm(ctx.Scope, jsii.String(fmt.Sprintf("%s_function_%d_%s", ctx.Id, i, metric.name)), &CloudwatchMetricAlarmConfig{
+				Provider:           ctx.Provider,
+				AlarmName:          jsii.String(*functionName + "-" + metric.name),
+				AlarmDescription:   jsii.String(fmt.Sprintf("[%s/function-%s/%s] - %s %s", *cfg.Name, metric.name, *functionName, *functionName, metric.text)),
+				Namespace:          jsii.String("AWS/Lambda"),
+				MetricName:         jsii.String(metric.metric),
+				Statistic:          jsii.String("Sum"),
+				ComparisonOperator: jsii.String("GreaterThanOrEqualToThreshold"),
+				Threshold:          jsii.Number(metric.threshold),
+				EvaluationPeriods:  jsii.Number(5),
+				Period:             jsii.Number(60),
+				DatapointsToAlarm:  jsii.Number(3),
+				TreatMissingData:   jsii.String("notBreaching"),
+				AlarmActions:       &actions,
+				OkActions:          &actions,
+				Dimensions: &map[string]*string{
+					"FunctionName": functionName,
+				},
+			}))
+		}
+	}
+
+	split := len(cfg.Functions) * len(functionMetrics)
+	return monitoringInstance{FunctionAlarms: alarms[:split], HealthAlarms: alarms[split:]}
+}
+
+// one map per health function alarm, keyed by region
+func (app monitoring) HealthAlarmIds() (result []map[string]common.ArnIdPair) {
+	for region, instance := range app.Regions {
+		for i, alarm := range instance.HealthAlarms {
+			if i == len(result) {
+				result = append(result, map[string]common.ArnIdPair{})
+			}
+			result[i][region] = common.ArnIdPair{Arn: alarm.Arn(), Id: alarm.AlarmName()}
+		}
+	}
+	return
+}
@@ -5,11 +5,15 @@ import (
 	"github.com/ContinentalBreakfast17/peppy/terraform/lib/_config"
 	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/api"
 	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/base"
+	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/bootstrap"
+	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/compliance"
 	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/healthcheck"
 	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/ip-lookup"
 	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/lock-table"
+	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/log-archive"
 	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/match-make"
 	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/match-publish"
+	. "github.com/ContinentalBreakfast17/peppy/terraform/lib/monitoring"
 	"github.com/aws/jsii-runtime-go"
 	"github.com/hashicorp/terraform-cdk-go/cdktf"
 )
@@ -23,9 +27,11 @@ func main() {
 	// read config
 	cfg, err := config.Paths{
 		// probably a better way to form these paths
-		Stacks: "config",
-		Schema: "../schema",
-		Vtl:    "../vtl",
+		Stacks:    "config",
+		Schema:    "../schema",
+		Vtl:       "../vtl",
+		Resolvers: "../resolvers",
+		Policies:  "../policies",
 	}.LoadConfig()
 
 	if err != nil {
@@ -35,11 +41,58 @@ func main() {
 	app := cdktf.NewApp(nil)
 	for _, stack := range cfg.Stacks {
 		(stackConfig{stack, cfg}).addTo(app)
+		if stack.Vars.Bootstrap.Enabled {
+			(stackConfig{stack, cfg}).addBootstrapTo(app)
+		}
 	}
 
 	app.Synth()
 }
 
+// applied once per account by an admin, before the stack's first deploy
+// accounts set up with the cloudformation templates need migrating first, see stacks/README.md
+func (cfg stackConfig) addBootstrapTo(app cdktf.App) {
+	stack := cdktf.NewTerraformStack(app, jsii.String(cfg.StackName+"-bootstrap"))
+
+	// the s3 backend is one of the things this creates, so the state stays in terraform.<stack>-bootstrap.tfstate
+	cdktf.NewLocalBackend(stack, &cdktf.LocalBackendProps{})
+
+	tags := TransformMapValues(cfg.Vars.Tags, jsii.String)
+
+	var adminGroup *string
+	if cfg.Vars.Bootstrap.AdminGroup != "" {
+		adminGroup = jsii.String(cfg.Vars.Bootstrap.AdminGroup)
+	}
+
+	bootstrap := BootstrapConfig{
+		Name:                      jsii.String(cfg.Vars.Name),
+		IamPath:                   jsii.String(cfg.Vars.IamPath),
+		Tags:                      &tags,
+		Regions:                   cfg.Vars.OrderedRegions(),
+		StateBucket:               jsii.String(cfg.Vars.Backend.Bucket),
+		StateKey:                  jsii.String(cfg.Vars.Backend.Key),
+		StateRegion:               jsii.String(cfg.Vars.Backend.Region),
+		StateTable:                jsii.String(cfg.Vars.Backend.Table),
+		ArtifactBucketPrefix:      jsii.String(cfg.Vars.Artifacts.BucketPrefix),
+		ArtifactPrefix:            jsii.String(cfg.Vars.Artifacts.ObjectPrefix),
+		ArtifactReaders:           cfg.Vars.Bootstrap.ArtifactReaders,
+		GithubRepo:                jsii.String(cfg.Vars.Bootstrap.GithubRepo),
+		Thumbprints:               cfg.Vars.Bootstrap.Thumbprints,
+		DeployerRole:              jsii.String(cfg.Vars.Bootstrap.DeployerRole),
+		TrustedPrincipals:         cfg.Vars.Bootstrap.TrustedPrincipals,
+		ExternalId:                cfg.Vars.Account.ExternalId,
+		AdminGroup:                adminGroup,
+		Policies:                  cfg.Policies,
+		PermissionsBoundary:       cfg.Vars.PermissionsBoundary.ArnOrNil(),
+		CreatePermissionsBoundary: cfg.Vars.PermissionsBoundary.Create,
+	}.New(SimpleContext(stack, "bootstrap", nil))
+
+	// goes into the repo's ROLE secret
+	cdktf.NewTerraformOutput(stack, jsii.String("deployer_role_arn"), &cdktf.TerraformOutputConfig{
+		Value: bootstrap.Deployer.Role.Arn(),
+	})
+}
+
 func (cfg stackConfig) addTo(app cdktf.App) {
 	stack := cdktf.NewTerraformStack(app, jsii.String(cfg.StackName))
 
@@ -55,90 +108,325 @@ func (cfg stackConfig) addTo(app cdktf.App) {
 		Prefix: jsii.String(cfg.Vars.Artifacts.ObjectPrefix),
 	}
 
+	tags := TransformMapValues(cfg.Vars.Tags, jsii.String)
+
+	backupPlans := map[string]BackupScheduleConfig{}
+	for name, plan := range cfg.Vars.Backups.Plans {
+		// copies live as long as the originals unless told otherwise
+		copyRetention := plan.CopyRetentionDays
+		if copyRetention == 0 {
+			copyRetention = plan.RetentionDays
+		}
+		backupPlans[name] = BackupScheduleConfig{
+			Schedule:          jsii.String(plan.Schedule),
+			RetentionDays:     jsii.Number(float64(plan.RetentionDays)),
+			CopyTo:            plan.CopyTo,
+			CopyRetentionDays: jsii.Number(float64(copyRetention)),
+		}
+	}
+
 	base := BaseConfig{
-		Name:           jsii.String(cfg.Vars.Name),
-		IamPath:        jsii.String(cfg.Vars.IamPath),
-		Regions:        cfg.Vars.Regions,
-		AdminGroupName: jsii.String(cfg.Vars.Groups.InfraAdmin),
-		Domain:         jsii.String(cfg.Vars.Domain.Name),
+		Name:    jsii.String(cfg.Vars.Name),
+		IamPath: jsii.String(cfg.Vars.IamPath),
+		Regions: cfg.Vars.Regions,
+		Tags:    &tags,
+		Account: AccountConfig{
+			Id:            cfg.Vars.Account.Id,
+			DeployRoleArn: cfg.Vars.Account.DeployRoleArn,
+			ExternalId:    cfg.Vars.Account.ExternalId,
+		},
+		KeyAdmins:          cfg.Vars.KeyAdmins.Principals,
+		KeyAdminPatterns:   cfg.Vars.KeyAdmins.Patterns,
+		KeyAdminGroups:     cfg.Vars.KeyAdmins.Groups,
+		Domain:             jsii.String(cfg.Vars.Domain.Name),
+		EncryptTopics:      cfg.Vars.Alarms.Encrypt,
+		SplitKeys:          cfg.Vars.Kms.SplitByDataClass,
+		DeletionProtection: cfg.Vars.Protection.Enabled,
+		Cost: CostConfig{
+			Enabled:            cfg.Vars.Cost.Enabled,
+			MonthlyLimit:       jsii.Number(cfg.Vars.Cost.MonthlyLimit),
+			ActualThresholds:   cfg.Vars.Cost.ActualThresholds,
+			ForecastThresholds: cfg.Vars.Cost.ForecastThresholds,
+			AnomalyThreshold:   jsii.Number(cfg.Vars.Cost.AnomalyThreshold),
+			ActivateTag:        cfg.Vars.Cost.ActivateTag,
+			AllocationTags:     append([]string{TAG_COMPONENT}, cfg.Vars.TagKeys()...),
+			SendTo:             cfg.Vars.Alarms.SendTo,
+		},
+		Backup: BackupConfig{
+			Plans: backupPlans,
+		},
+		PermissionsBoundary:       cfg.Vars.PermissionsBoundary.ArnOrNil(),
+		CreatePermissionsBoundary: cfg.Vars.PermissionsBoundary.Create,
 	}.New(SimpleContext(stack, "base", nil))
 
 	allProviders := base.Providers.All()
 	lambdaIam := LambdaIamConfig{
-		Path:       jsii.String(cfg.Vars.IamPath),
-		ExecPolicy: base.Policies.LambdaExec.Arn(),
-		AssumeRole: base.Policies.LambdaAssumeRole.Json(),
+		Path:                jsii.String(cfg.Vars.IamPath),
+		ExecPolicy:          base.Policies.LambdaExec.Arn(),
+		AssumeRole:          base.Policies.LambdaAssumeRole.Json(),
+		PermissionsBoundary: base.Policies.PermissionsBoundary,
+	}
+	tracing := LambdaTracingConfig{
+		Enabled: cfg.Vars.Tracing.Enabled,
+		Policy:  base.Policies.XrayWrite.Arn(),
+	}
+	logs := LogGroupConfig{
+		Retention: jsii.Number(float64(cfg.Vars.Logging.Retention)),
+		Encrypt:   cfg.Vars.Logging.Encrypt,
+		KmsArns:   base.Kms.State.Arns(),
+	}
+
+	if cfg.Vars.Logging.Archive.Enabled {
+		logArchive := LogArchiveConfig{
+			Providers:            allProviders,
+			Name:                 jsii.String(cfg.Vars.Name),
+			IamPath:              jsii.String(cfg.Vars.IamPath),
+			PermissionsBoundary:  base.Policies.PermissionsBoundary,
+			AccountId:            base.DataSources.AccountId(),
+			KmsArns:              base.Kms.State.Arns(),
+			InfrequentAccessDays: jsii.Number(float64(cfg.Vars.Logging.Archive.InfrequentAccessDays)),
+			GlacierDays:          jsii.Number(float64(cfg.Vars.Logging.Archive.GlacierDays)),
+			ExpirationDays:       jsii.Number(float64(cfg.Vars.Logging.Archive.ExpirationDays)),
+		}.New(SimpleContext(stack, "log_archive", base.Providers.Main))
+		logs.Archive = logArchive.DestinationArns()
+	}
+
+	iteratorAge := IteratorAgeAlarmConfig{
+		Threshold:         jsii.Number(float64(cfg.Vars.IteratorAge.Threshold)),
+		EvaluationPeriods: jsii.Number(float64(cfg.Vars.IteratorAge.EvaluationPeriods)),
+		DatapointsToAlarm: jsii.Number(float64(cfg.Vars.IteratorAge.DatapointsToAlarm)),
 	}
 
 	// meaningful resources start here
 
 	ipLookup := IpLookupConfig{
-		Providers:     allProviders,
-		Name:          jsii.String(cfg.Vars.Name + "-ip-lookup"),
-		LambdaIam:     lambdaIam,
-		Code:          codeObjectConfig,
-		KmsReadPolicy: base.Policies.KmsMain.Read.Arn(),
-		KmsArns:       base.KmsMain.Arns(),
+		Providers:          allProviders,
+		Name:               jsii.String(cfg.Vars.Name + "-ip-lookup"),
+		LambdaIam:          lambdaIam,
+		Tracing:            tracing,
+		Logs:               logs,
+		Code:               codeObjectConfig,
+		KmsReadPolicy:      base.Policies.Kms.Secrets.Read.Arn(),
+		KmsArns:            base.Kms.Secrets.Arns(),
+		DeletionProtection: cfg.Vars.Protection.Enabled,
 	}.New(SimpleContext(stack, "ip_lookup", base.Providers.Main))
 
 	lockTable := LockTableConfig{
-		Providers: allProviders,
-		Name:      jsii.String(cfg.Vars.Name + "-process-lock"),
-		KmsArns:   base.KmsMain.Arns(),
+		Providers:           allProviders,
+		Name:                jsii.String(cfg.Vars.Name + "-process-lock"),
+		LambdaIam:           lambdaIam,
+		Tracing:             tracing,
+		Logs:                logs,
+		Code:                codeObjectConfig,
+		KmsWritePolicy:      base.Policies.Kms.State.Write.Arn(),
+		KmsArns:             base.Kms.State.Arns(),
+		MetricNamespace:     jsii.String(cfg.Vars.MetricNamespace()),
+		Regions:             cfg.Vars.OrderedRegions(),
+		PointInTimeRecovery: cfg.Vars.Backups.Tables.Locks.Pitr,
+		DeletionProtection:  cfg.Vars.Protection.Enabled,
 	}.New(SimpleContext(stack, "process_lock", base.Providers.Main))
 
-	matchPublish := MatchPublishConfig{
-		Providers:     allProviders,
-		Name:          jsii.String(cfg.Vars.Name + "-match-publish"),
-		LambdaIam:     lambdaIam,
-		Code:          codeObjectConfig,
-		KmsReadPolicy: base.Policies.KmsMain.Read.Arn(),
-		KmsArns:       base.KmsMain.Arns(),
-		ApiUrl:        cfg.Vars.Domain.RegionalUrlTemplate(),
-	}.New(SimpleContext(stack, "match_publish", base.Providers.Main))
-
 	healthcheck := HealthcheckConfig{
-		Providers:      allProviders,
-		Name:           jsii.String(cfg.Vars.Name + "-healthcheck"),
-		LambdaIam:      lambdaIam,
-		Code:           codeObjectConfig,
-		KmsReadPolicy:  base.Policies.KmsMain.Read.Arn(),
-		KmsWritePolicy: base.Policies.KmsMain.Write.Arn(),
-		KmsArns:        base.KmsMain.Arns(),
-		ApiUrl:         cfg.Vars.Domain.RegionalUrlTemplate(),
-		SendAlarmsTo:   cfg.Vars.Alarms.SendTo,
+		Providers:       allProviders,
+		Name:            jsii.String(cfg.Vars.Name + "-healthcheck"),
+		LambdaIam:       lambdaIam,
+		Tracing:         tracing,
+		Logs:            logs,
+		Code:            codeObjectConfig,
+		KmsReadPolicy:   base.Policies.Kms.State.Read.Arn(),
+		KmsWritePolicy:  base.Policies.Kms.State.Write.Arn(),
+		KmsArns:         base.Kms.State.Arns(),
+		IteratorAge:     iteratorAge,
+		AccountId:       base.DataSources.AccountId(),
+		ApiUrl:          cfg.Vars.Domain.RegionalUrlTemplate(),
+		SendAlarmsTo:    cfg.Vars.Alarms.SendTo,
+		EncryptTopics:   cfg.Vars.Alarms.Encrypt,
+		Schedule:        jsii.String(cfg.Vars.Healthcheck.Schedule),
+		Ttl:             jsii.Number(float64(cfg.Vars.Healthcheck.Ttl)),
+		MetricNamespace: jsii.String(cfg.Vars.MetricNamespace()),
+		Policy: HealthcheckAlarmPolicy{
+			Threshold:         jsii.Number(cfg.Vars.Healthcheck.Threshold),
+			Period:            jsii.Number(float64(cfg.Vars.Healthcheck.Period)),
+			EvaluationPeriods: jsii.Number(float64(cfg.Vars.Healthcheck.EvaluationPeriods)),
+			DatapointsToAlarm: jsii.Number(float64(cfg.Vars.Healthcheck.DatapointsToAlarm)),
+			TreatMissingData:  jsii.String(cfg.Vars.Healthcheck.TreatMissingData),
+		},
+		Canary: HealthcheckCanaryConfig{
+			Enabled:           cfg.Vars.Healthcheck.Canary.Enabled,
+			Schedule:          jsii.String(cfg.Vars.Healthcheck.Canary.Schedule),
+			Deadline:          jsii.Number(float64(cfg.Vars.Healthcheck.Canary.Deadline)),
+			Period:            jsii.Number(float64(cfg.Vars.Healthcheck.Canary.Period)),
+			EvaluationPeriods: jsii.Number(float64(cfg.Vars.Healthcheck.Canary.EvaluationPeriods)),
+			DatapointsToAlarm: jsii.Number(float64(cfg.Vars.Healthcheck.Canary.DatapointsToAlarm)),
+		},
+		Probe: HealthcheckProbeConfig{
+			Enabled:           cfg.Vars.Healthcheck.Probe.Enabled,
+			Quorum:            cfg.Vars.Healthcheck.Probe.Quorum,
+			EvaluationPeriods: jsii.Number(float64(cfg.Vars.Healthcheck.Probe.EvaluationPeriods)),
+			DatapointsToAlarm: jsii.Number(float64(cfg.Vars.Healthcheck.Probe.DatapointsToAlarm)),
+		},
 	}.New(SimpleContext(stack, "healthcheck", base.Providers.Main))
 
+	// everything else's stream alarms go to the healthcheck topics too
+	iteratorAge.Topics = healthcheck.Alarm.TopicArns()
+
+	matchPublish := MatchPublishConfig{
+		Providers:           allProviders,
+		Name:                jsii.String(cfg.Vars.Name + "-match-publish"),
+		LambdaIam:           lambdaIam,
+		Tracing:             tracing,
+		Logs:                logs,
+		IteratorAge:         iteratorAge,
+		Code:                codeObjectConfig,
+		KmsReadPolicy:       base.Policies.Kms.State.Read.Arn(),
+		KmsArns:             base.Kms.State.Arns(),
+		ApiUrl:              cfg.Vars.Domain.RegionalUrlTemplate(),
+		PointInTimeRecovery: cfg.Vars.Backups.Tables.Matches.Pitr,
+		DeletionProtection:  cfg.Vars.Protection.Enabled,
+	}.New(SimpleContext(stack, "match_publish", base.Providers.Main))
+
 	matchMake := MatchMakeConfig{
 		Providers:      allProviders,
 		Name:           jsii.String(cfg.Vars.Name + "-match-make"),
 		LambdaIam:      lambdaIam,
+		Tracing:        tracing,
+		Logs:           logs,
+		IteratorAge:    iteratorAge,
 		Code:           codeObjectConfig,
-		KmsWritePolicy: base.Policies.KmsMain.Write.Arn(),
-		KmsArns:        base.KmsMain.Arns(),
+		KmsWritePolicy: base.Policies.Kms.State.Write.Arn(),
+		KmsArns:        base.Kms.State.Arns(),
 		MatchTables:    matchPublish.TableIds(),
 		LockTables:     lockTable.TableIds(),
 		LockRegions:    cfg.Vars.OrderedRegions(),
-		AlarmIds:       healthcheck.Alarm.AlarmIds(),
+		AlarmIds: []map[string]ArnIdPair{
+			healthcheck.Alarm.AlarmIds(),
+			lockTable.Checker.AlarmIds(),
+		},
+		PointInTimeRecovery: cfg.Vars.Backups.Tables.Queues.Pitr,
+		DeletionProtection:  cfg.Vars.Protection.Enabled,
 	}.New(SimpleContext(stack, "match_make", base.Providers.Main))
 
+	monitoringFunctions := []map[string]ArnIdPair{
+		ipLookup.FunctionIds(),
+		lockTable.Checker.FunctionIds(),
+		matchPublish.FunctionIds(),
+		healthcheck.Healthchecker.FunctionIds(),
+		healthcheck.Responder.FunctionIds(),
+	}
+
+	// only the queue processors take a region out of dns, the rest just notify
+	monitoring := MonitoringConfig{
+		Providers:       allProviders,
+		Name:            jsii.String(cfg.Vars.Name + "-monitoring"),
+		Functions:       monitoringFunctions,
+		HealthFunctions: matchMake.FunctionIds(),
+		Topics:          healthcheck.Alarm.TopicArns(),
+	}.New(SimpleContext(stack, "monitoring", base.Providers.Main))
+
+	regionHealthAlarms := []map[string]ArnIdPair{
+		healthcheck.Alarm.AlarmIds(),
+	}
+	if cfg.Vars.IteratorAge.AffectsHealth {
+		regionHealthAlarms = append(regionHealthAlarms, matchPublish.IteratorAgeAlarmIds(), healthcheck.Responder.IteratorAgeAlarmIds())
+		regionHealthAlarms = append(regionHealthAlarms, matchMake.IteratorAgeAlarmIds()...)
+	}
+	if cfg.Vars.Healthcheck.Probe.AffectsHealth && healthcheck.ProbeAlarm.Regions != nil {
+		regionHealthAlarms = append(regionHealthAlarms, healthcheck.ProbeAlarm.AlarmIds())
+	}
+	regionHealthAlarms = append(regionHealthAlarms, monitoring.HealthAlarmIds()...)
+
 	api := ApiConfig{
-		Providers:         allProviders,
-		Name:              jsii.String(cfg.Vars.Name),
-		Schema:            cfg.Schema,
-		Vtl:               cfg.Vtl,
-		KmsArns:           base.KmsMain.Arns(),
-		KmsWritePolicy:    base.Policies.KmsMain.Write.Arn(),
-		DomainName:        jsii.String(cfg.Vars.Domain.Fqdn()),
-		HostedZoneId:      base.DataSources.HostedZone.Id(),
-		FunctionsIpLookup: ipLookup.FunctionIds(),
-		TablesHealthcheck: healthcheck.Healthchecker.TableIds(),
-		AlarmsHealthCheck: healthcheck.Alarm.AlarmIds(),
+		Providers: allProviders,
+		Name:      jsii.String(cfg.Vars.Name),
+		Schema:    cfg.Schema,
+		Vtl:       cfg.Vtl,
+		Resolvers: cfg.resolvers(),
+		Tracing:   cfg.Vars.Tracing.Enabled,
+		KmsArns:   base.Kms.Pii.Arns(),
+		PointInTimeRecovery: ApiPitrConfig{
+			Users:   cfg.Vars.Backups.Tables.Users.Pitr,
+			IpCache: cfg.Vars.Backups.Tables.IpCache.Pitr,
+		},
+		DeletionProtection:  cfg.Vars.Protection.Enabled,
+		KmsWritePolicies:    base.Policies.Kms.Writes(base.Policies.Kms.State, base.Policies.Kms.Pii),
+		PermissionsBoundary: base.Policies.PermissionsBoundary,
+		DomainName:          jsii.String(cfg.Vars.Domain.Fqdn()),
+		HostedZoneId:        base.DataSources.HostedZone.Id(),
+		FunctionsIpLookup:   ipLookup.FunctionIds(),
+		TablesHealthcheck:   healthcheck.Healthchecker.TableIds(),
+		TablesLock:          lockTable.TableIds(),
+		AlarmsRegionHealth:  regionHealthAlarms,
+		TopicsRegionHealth:  healthcheck.Alarm.TopicArns(),
+		HealthcheckTtl:      healthcheck.Ttl,
 		Queues: ApiQueueConfig{
 			UnrankedSolo: matchMake.UnrankedSolo,
+			Healthcheck:  matchMake.Healthcheck,
+		},
+		Cache: ApiCacheConfig{
+			Enabled:    cfg.Vars.Cache.Enabled,
+			Type:       jsii.String(cfg.Vars.Cache.Type),
+			Ttl:        jsii.Number(float64(cfg.Vars.Cache.Ttl)),
+			MinHitRate: cfg.Vars.Cache.MinHitRate,
+			Topics:     healthcheck.Alarm.TopicArns(),
+		},
+		Logging: ApiLoggingConfig{
+			FieldLogLevel:         jsii.String(cfg.Vars.Logging.FieldLogLevel),
+			ExcludeVerboseContent: cfg.Vars.Logging.ExcludeVerboseContent,
+			Logs:                  logs,
 		},
 	}.New(SimpleContext(stack, "api", base.Providers.Main))
 
+	DashboardConfig{
+		Providers:          allProviders,
+		Name:               jsii.String(cfg.Vars.Name),
+		Apis:               api.ApiIds(),
+		RegionHealthAlarms: api.RegionHealthAlarmIds(),
+		Functions:          append(monitoringFunctions, matchMake.FunctionIds()...),
+		StreamFunctions: append([]map[string]ArnIdPair{
+			matchPublish.FunctionIds(),
+			healthcheck.Responder.FunctionIds(),
+		}, matchMake.FunctionIds()...),
+		Tables: append([]map[string]ArnIdPair{
+			matchPublish.TableIds(),
+			lockTable.TableIds(),
+		}, matchMake.TableIds()...),
+	}.New(SimpleContext(stack, "dashboard", base.Providers.Main))
+
+	BusinessMetricsConfig{
+		Providers:      allProviders,
+		Name:           jsii.String(cfg.Vars.Name),
+		Namespace:      jsii.String(cfg.Vars.MetricNamespace()),
+		ApiLogGroups:   api.LogGroupIds(),
+		QueueLogGroups: matchMake.PlayerLogGroupIds(),
+	}.New(SimpleContext(stack, "business_metrics", base.Providers.Main))
+
+	// scheduled backups, tables without a plan are skipped
+	base.Backup.Select(
+		SimpleContext(stack, "backup_users", base.Providers.Main),
+		cfg.Vars.Backups.Tables.Users.Plan,
+		api.UserTableIds(),
+	)
+	base.Backup.Select(
+		SimpleContext(stack, "backup_ip_cache", base.Providers.Main),
+		cfg.Vars.Backups.Tables.IpCache.Plan,
+		api.IpCacheTableIds(),
+	)
+	base.Backup.Select(
+		SimpleContext(stack, "backup_queues", base.Providers.Main),
+		cfg.Vars.Backups.Tables.Queues.Plan,
+		matchMake.TableIds()...,
+	)
+	base.Backup.Select(
+		SimpleContext(stack, "backup_matches", base.Providers.Main),
+		cfg.Vars.Backups.Tables.Matches.Plan,
+		matchPublish.TableIds(),
+	)
+	base.Backup.Select(
+		SimpleContext(stack, "backup_locks", base.Providers.Main),
+		cfg.Vars.Backups.Tables.Locks.Plan,
+		lockTable.TableIds(),
+	)
+
 	// add api permissions to lambdas
 	matchPublish.AddApiPerms(
 		SimpleContext(stack, "match_publish_api_perms", base.Providers.Main),
@@ -152,4 +440,53 @@ func (cfg stackConfig) addTo(app cdktf.App) {
 		SimpleContext(stack, "healthcheck_responder_api_perms", base.Providers.Main),
 		ArnsToList(api.ApiIds()),
 	)
+	healthcheck.Canary.AddApiPerms(
+		SimpleContext(stack, "healthcheck_canary_api_perms", base.Providers.Main),
+		ArnsToList(api.ApiIds()),
+	)
+
+	// aspects run in the order they were added, so this goes after the component tags
+	ComplianceConfig{
+		Name:              jsii.String(cfg.Vars.Name),
+		Encryption:        Level(cfg.Vars.Compliance.Encryption),
+		Retention:         Level(cfg.Vars.Compliance.Retention),
+		Wildcards:         Level(cfg.Vars.Compliance.Wildcards),
+		DeadLetters:       Level(cfg.Vars.Compliance.DeadLetters),
+		Tags:              Level(cfg.Vars.Compliance.Tags),
+		UnencryptedTables: cfg.Vars.Compliance.UnencryptedTables,
+		RequiredTags:      cfg.Vars.Compliance.RequiredTags,
+	}.New(SimpleContext(stack, "compliance", nil))
+}
+
+// the api doesn't know about the manifest files
+func (cfg stackConfig) resolvers() []ApiResolverConfig {
+	resolvers := []ApiResolverConfig{}
+	for _, resolver := range cfg.Resolvers {
+		stash := map[string]string{}
+		for key, value := range resolver.Stash {
+			stash[key] = string(value)
+		}
+
+		var caching *ApiResolverCachingConfig
+		if resolver.Caching != nil {
+			caching = &ApiResolverCachingConfig{
+				Ttl:  resolver.Caching.Ttl,
+				Keys: resolver.Caching.Keys,
+			}
+		}
+
+		resolvers = append(resolvers, ApiResolverConfig{
+			Type:        resolver.Type,
+			Field:       resolver.Field,
+			Functions:   resolver.Functions,
+			DataSource:  resolver.DataSource,
+			Request:     resolver.Request,
+			Response:    resolver.Response,
+			Stash:       stash,
+			StashArgs:   resolver.StashArgs,
+			StashTables: resolver.StashTables,
+			Caching:     caching,
+		})
+	}
+	return resolvers
 }